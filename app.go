@@ -1,43 +1,96 @@
 package main
 
 import (
-	"bytes"
+	"bufio"
 	"encoding/json"
 	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ec2"
 	"github.com/aws/aws-sdk-go/service/emr"
 	"github.com/aws/aws-sdk-go/service/emr/emriface"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/ssm"
 	"gopkg.in/urfave/cli.v1"
-	"gopkg.in/yaml.v2"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"os/exec"
+	"path"
+	"regexp"
 	"sort"
 	"strconv"
 	"strings"
 	"syscall"
-	"text/template"
 	"time"
 )
 
 type App struct {
-	EMRAPI    emriface.EMRAPI
-	Stdout    io.Writer
-	Stderr    io.Writer
-	OpHandler OperationHandler
+	EMRAPI      emriface.EMRAPI
+	Stdin       io.Reader
+	Stdout      io.Writer
+	Stderr      io.Writer
+	OpHandler   OperationHandler
+	Secrets     SecretStore
+	Pricing     PriceSource
+	SpotPricing SpotPriceSource
+	CacheDir    string
+	ProfileDir  string
+
+	// AWS profile and region of the API clients, which the caches of API results are kept per
+	AWSProfile string
+	AWSRegion  string
+
+	// Stdin is a terminal, so that confirmations can be answered
+	Interactive bool
+
+	// reader of Stdin kept across confirmations not to lose buffered answers
+	stdinReader *bufio.Reader
 }
 
 func NewApp() *App {
 	sess := session.Must(session.NewSession())
 	return &App{
 		EMRAPI:    emr.New(sess),
+		Stdin:     os.Stdin,
 		Stdout:    os.Stdout,
 		Stderr:    cli.ErrWriter,
 		OpHandler: &OperationHandle{},
+		Secrets: &AWSSecretStore{
+			SSMAPI:            ssm.New(sess),
+			SecretsManagerAPI: secretsmanager.New(sess),
+		},
+		// the Price List API is served in us-east-1
+		Pricing: &AWSPriceSource{
+			PricingAPI: pricing.New(sess, aws.NewConfig().WithRegion("us-east-1")),
+			Region:     aws.StringValue(sess.Config.Region),
+		},
+		SpotPricing: &AWSSpotPriceSource{EC2API: ec2.New(sess)},
+		CacheDir:    path.Join(os.Getenv("HOME"), ".emrcmd", "cache"),
+		ProfileDir:  profileDir(),
+		AWSProfile:  awsProfile(),
+		AWSRegion:   aws.StringValue(sess.Config.Region),
+		Interactive: isTerminal(os.Stdin),
+	}
+}
+
+// awsProfile returns the shared config profile used by the AWS SDK.
+func awsProfile() string {
+	for _, k := range []string{"AWS_PROFILE", "AWS_DEFAULT_PROFILE"} {
+		if p := os.Getenv(k); p != "" {
+			return p
+		}
 	}
+	return "default"
+}
+
+func profileDir() string {
+	if dir := os.Getenv("EMR_CLUSTER_PROFILE_DIR"); dir != "" {
+		return dir
+	}
+	return path.Join(os.Getenv("HOME"), ".emrcmd", "clusters")
 }
 
 type OperationHandler interface {
@@ -66,6 +119,8 @@ func (c *OperationHandle) Exec(args []string) error {
 	return syscall.Exec(cmd, args, env)
 }
 
+var clusterIdPattern = regexp.MustCompile(`^j-[0-9A-Z]+$`)
+
 var (
 	ClusterStateAll = []string{
 		emr.ClusterStateStarting,
@@ -87,73 +142,49 @@ var (
 /*
  * Helper Functions
  */
-func loadClusterConfig(filename string, name string, vars map[string]string) (*emr.RunJobFlowInput, error) {
-	funcMap := template.FuncMap{
-		"name": func() string { return name },
-		"lookup": func(key string, defval interface{}) interface{} {
-			val, ok := vars[key]
-			if ok {
-				return val
-			}
-
-			val, ok = os.LookupEnv("EMR_VAR_" + strings.ToUpper(key))
-			if ok {
-				return val
-			}
-
-			return defval
-		},
-		"env": os.Getenv,
-	}
-
-	dat, err := ioutil.ReadFile(filename)
-	if err != nil {
-		return nil, err
-	}
-
-	t, err := template.New("cluster").Funcs(funcMap).Parse(string(dat))
-	if err != nil {
-		return nil, err
-	}
-
-	buf := bytes.NewBufferString("")
-	err = t.Execute(buf, vars)
-	if err != nil {
-		return nil, err
-	}
-
-	ret := emr.RunJobFlowInput{}
-	err = yaml.Unmarshal(buf.Bytes(), &ret)
-	if err != nil {
-		return nil, err
-	}
-
-	return &ret, nil
-}
-
 func (s *App) FindByName(name string) (*emr.ClusterSummary, error) {
 	in := emr.ListClustersInput{
 		ClusterStates: aws.StringSlice(ClusterStateActive),
 	}
 
-	var ret *emr.ClusterSummary
+	var found []*emr.ClusterSummary
 	err := s.EMRAPI.ListClustersPages(&in, func(out *emr.ListClustersOutput, b bool) bool {
 		for _, c := range out.Clusters {
 			if aws.StringValue(c.Name) == name {
-				ret = c
-				return false
+				found = append(found, c)
 			}
 		}
 		return true
 	})
 	if err != nil {
-		return nil, err
+		return nil, apiError("ListClusters", err)
 	}
-	if ret == nil {
-		return nil, fmt.Errorf("cluster %s is not found", name)
+	if len(found) == 0 {
+		return nil, &NotFoundError{Kind: "cluster", Name: name}
+	}
+	if len(found) > 1 {
+		var ids []string
+		for _, c := range found {
+			ids = append(ids, aws.StringValue(c.Id))
+		}
+		return nil, &AmbiguousError{Kind: "cluster", Name: name, Candidates: ids}
 	}
 
-	return ret, nil
+	return found[0], nil
+}
+
+// FindClusterId returns the id of the active cluster with the name.
+// A cluster id (j-XXXXXXXX) is returned as is.
+func (s *App) FindClusterId(nameOrId string) (string, error) {
+	if clusterIdPattern.MatchString(nameOrId) {
+		return nameOrId, nil
+	}
+
+	c, err := s.FindByName(nameOrId)
+	if err != nil {
+		return "", err
+	}
+	return aws.StringValue(c.Id), nil
 }
 
 func (s *App) FindInstanceGroupByName(id string, name string) (*emr.InstanceGroup, error) {
@@ -170,16 +201,53 @@ func (s *App) FindInstanceGroupByName(id string, name string) (*emr.InstanceGrou
 		return true
 	})
 	if err != nil {
-		return nil, err
+		return nil, apiError("ListInstanceGroups", err)
 	}
 	return ret, nil
 }
 
+// newConfigLoader returns the loader of cluster config templates with the variables.
+func (s *App) newConfigLoader(name string, vars map[string]string, varFiles []string) (*configLoader, error) {
+	loader := newConfigLoader(name, vars)
+	loader.secrets = s.Secrets
+	loader.spotPrices = s.SpotPricing
+
+	err := loader.LoadVarFiles(varFiles)
+	if err != nil {
+		return nil, err
+	}
+	return loader, nil
+}
+
+// isTerminal returns true if the file is a character device such as a terminal, not a pipe or a file.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// confirm asks the question on Stderr and returns true if the answer is yes.
+func (s *App) confirm(question string) (bool, error) {
+	fmt.Fprintf(s.Stderr, "%s [y/N] ", question)
+	if s.stdinReader == nil {
+		s.stdinReader = bufio.NewReader(s.Stdin)
+	}
+	answer, err := s.stdinReader.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
 func (s *App) GetMaster(id string) (string, error) {
 	in := emr.DescribeClusterInput{ClusterId: aws.String(id)}
 	out, err := s.EMRAPI.DescribeCluster(&in)
 	if err != nil {
-		return "", err
+		return "", apiError("DescribeCluster", err)
 	}
 	return aws.StringValue(out.Cluster.MasterPublicDnsName), nil
 }
@@ -188,55 +256,119 @@ func (s *App) GetMaster(id string) (string, error) {
  * Start new cluster
  */
 type AppStartOptions struct {
-	Name     string
-	Vars     map[string]string
-	Filename string
-	DryRun   bool
+	Name string
+	Vars map[string]string
+	TemplateOptions
+	DryRun  bool
+	Output  string
+	Explain bool
+	TTL     time.Duration // tag the cluster to be reaped after TTL
+
+	// prices for the estimated cost printed in dry-run
+	PriceFile  string
+	PricingAPI bool
 }
 
 func (s *App) Start(o *AppStartOptions) error {
-	config, err := loadClusterConfigForStart(o.Filename, o.Name, o.Vars)
+	filename, err := s.ConfigFile(o.Filename, o.Profile)
 	if err != nil {
 		return err
 	}
 
+	loader, err := s.newConfigLoader(o.Name, o.Vars, o.VarFiles)
+	if err != nil {
+		return err
+	}
+
+	t, err := loadClusterConfigForStart(loader, filename)
+	if err != nil {
+		return err
+	}
+	config := &t.RunJobFlowInput
+
+	if o.TTL > 0 {
+		setExpiresAt(config, time.Now(), o.TTL)
+	}
+
+	if o.Explain {
+		loader.Explain(s.Stderr)
+	}
+
 	if o.DryRun {
+		// JSON is given to `aws emr create-cluster --cli-input-json`
+		var v interface{} = config
+		if o.Output == OutputFormatJSON {
+			v, err = createClusterInput(config)
+			if err != nil {
+				return err
+			}
+		}
+
 		fmt.Fprintln(s.Stderr, "Start cluster with:")
-		fmt.Fprintln(s.Stdout, config)
-		return nil
+		err := loader.WriteConfig(s.Stdout, v, o.Output)
+		if err != nil {
+			return err
+		}
+
+		prices, err := s.newPriceSource(o.PriceFile, o.PricingAPI)
+		if err != nil || prices == nil {
+			return err
+		}
+		return s.printHourlyCost(config, prices)
 	}
 
 	fmt.Fprintf(s.Stderr, "starting cluster %s ...\n", o.Name)
 
 	out, err := s.EMRAPI.RunJobFlow(config)
 	if err != nil {
-		return err
+		return apiError("RunJobFlow", err)
 	}
 
 	err = s.EMRAPI.WaitUntilClusterRunning(&emr.DescribeClusterInput{ClusterId: out.JobFlowId})
 	if err != nil {
-		return err
+		return apiError("WaitUntilClusterRunning", err)
 	}
 
 	return nil
 }
 
-func loadClusterConfigForStart(filename string, name string, vars map[string]string) (*emr.RunJobFlowInput, error) {
-	config, err := loadClusterConfig(filename, name, vars)
+func loadClusterConfigForStart(loader *configLoader, filename string) (*ClusterTemplate, error) {
+	t, err := loader.LoadTemplate(filename)
 	if err != nil {
 		return nil, err
 	}
+	config := &t.RunJobFlowInput
+
+	// the cluster starts with its scaling policies, so that nothing is left to put after launch
+	if t.Scaling != nil {
+		setScaling(config, t.Scaling)
+	}
+	if t.IdleTimeout != "" {
+		err := setIdleTimeout(config, t.IdleTimeout)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	igs := config.Instances.InstanceGroups
 	var newIgs []*emr.InstanceGroupConfig
 	for _, ig := range igs {
-		if aws.Int64Value(ig.InstanceCount) > 0 {
+		// keep empty groups grown by auto-scaling
+		if aws.Int64Value(ig.InstanceCount) > 0 || ig.AutoScalingPolicy != nil {
 			newIgs = append(newIgs, ig)
 		}
 	}
 	config.Instances.InstanceGroups = newIgs
 
-	return config, nil
+	var newFleets []*emr.InstanceFleetConfig
+	for _, f := range config.Instances.InstanceFleets {
+		if aws.Int64Value(f.TargetOnDemandCapacity)+aws.Int64Value(f.TargetSpotCapacity) > 0 {
+			newFleets = append(newFleets, f)
+		}
+	}
+	config.Instances.InstanceFleets = newFleets
+
+	return t, nil
 }
 
 /*
@@ -272,7 +404,7 @@ func (s *App) List(o *AppListOptions) (err error) {
 		return true
 	})
 	if e != nil && err == nil {
-		err = e
+		err = apiError("ListClusters", e)
 	}
 	return
 }
@@ -295,12 +427,25 @@ func (s *App) printClusterInfo(o *AppListOptions, cluster *emr.ClusterSummary) e
 		fmt.Fprintln(s.Stdout, "  Master: "+master)
 	}
 
-	// Cluster Metrics
+	// Cluster Metrics and idle time
 	if !o.NoMetrics && master != "" && (state == emr.ClusterStateRunning || state == emr.ClusterStateWaiting) {
-		err := s.printClusterMetrics(master)
+		var ready time.Time
+		if tl := cluster.Status.Timeline; tl != nil {
+			ready = aws.TimeValue(tl.ReadyDateTime)
+		}
+		activity, err := s.getClusterActivity(id, master, ready)
 		if err != nil {
 			return err
 		}
+
+		err = s.printClusterMetrics(master)
+		if err != nil {
+			return err
+		}
+
+		if a := activity.String(); a != "unknown" {
+			fmt.Fprintln(s.Stdout, "  Idle: "+a)
+		}
 	}
 
 	// Cluster Size
@@ -353,7 +498,7 @@ type ClusterMetricsBuffer struct {
 func (s *App) getClusterMetrics(url string) (*ClusterMetrics, error) {
 	buf, err := s.OpHandler.HttpGet(url)
 	if err != nil {
-		return nil, err
+		return nil, &UnreachableError{URL: url, Err: err}
 	}
 
 	dat := ClusterMetricsBuffer{}
@@ -363,7 +508,10 @@ func (s *App) getClusterMetrics(url string) (*ClusterMetrics, error) {
 	}
 
 	ret := dat.ClusterMetrics
-	ret.MemoryUsed = int(float64(ret.AllocatedMB) / float64(ret.TotalMB) * 100)
+	// no memory is available before NodeManagers join or after all of them are lost
+	if ret.TotalMB > 0 {
+		ret.MemoryUsed = int(float64(ret.AllocatedMB) / float64(ret.TotalMB) * 100)
+	}
 
 	return &ret, nil
 }
@@ -371,36 +519,60 @@ func (s *App) getClusterMetrics(url string) (*ClusterMetrics, error) {
 func (s *App) printClusterSize(id string) error {
 	fmt.Fprintln(s.Stdout, "  Nodes:")
 
+	out, err := s.EMRAPI.DescribeCluster(&emr.DescribeClusterInput{ClusterId: aws.String(id)})
+	if err != nil {
+		return apiError("DescribeCluster", err)
+	}
+	if aws.StringValue(out.Cluster.InstanceCollectionType) == emr.InstanceCollectionTypeInstanceFleet {
+		return s.printClusterFleetSize(id)
+	}
+
 	in := emr.ListInstanceGroupsInput{ClusterId: aws.String(id)}
 	var igs []*emr.InstanceGroup
-	err := s.EMRAPI.ListInstanceGroupsPages(&in, func(out *emr.ListInstanceGroupsOutput, b bool) bool {
+	err = s.EMRAPI.ListInstanceGroupsPages(&in, func(out *emr.ListInstanceGroupsOutput, b bool) bool {
 		for _, ig := range out.InstanceGroups {
 			igs = append(igs, ig)
 		}
 		return true
 	})
 	if err != nil {
-		return err
+		return apiError("ListInstanceGroups", err)
 	}
 
-	sort.Slice(igs, func(i, j int) bool {
-		return encodeInstanceGroupType(igs[i].InstanceGroupType) < encodeInstanceGroupType(igs[j].InstanceGroupType)
-	})
+	sortInstanceGroups(igs)
 
 	for _, ig := range igs {
 		var name = aws.StringValue(ig.Name)
 		var run = aws.Int64Value(ig.RunningInstanceCount)
 		var req = aws.Int64Value(ig.RequestedInstanceCount)
-		if run == req {
-			fmt.Fprintf(s.Stdout, "    %s: %d\n", name, run)
-		} else {
-			fmt.Fprintf(s.Stdout, "    %s: %d(%d)\n", name, run, req)
-		}
+		fmt.Fprintf(s.Stdout, "    %s: %s\n", name, formatCapacity(run, req))
+	}
+
+	return nil
+}
+
+func (s *App) printClusterFleetSize(id string) error {
+	fleets, err := s.listInstanceFleets(id)
+	if err != nil {
+		return err
+	}
+
+	sortInstanceFleets(fleets)
+
+	for _, f := range fleets {
+		fmt.Fprintf(s.Stdout, "    %s: %s\n", aws.StringValue(f.Name), formatFleetCapacity(f))
 	}
 
 	return nil
 }
 
+// sortInstanceGroups sorts instance groups in MASTER, CORE and TASK order.
+func sortInstanceGroups(igs []*emr.InstanceGroup) {
+	sort.SliceStable(igs, func(i, j int) bool {
+		return encodeInstanceGroupType(igs[i].InstanceGroupType) < encodeInstanceGroupType(igs[j].InstanceGroupType)
+	})
+}
+
 func encodeInstanceGroupType(t *string) int {
 	switch aws.StringValue(t) {
 	case emr.InstanceGroupTypeMaster:
@@ -420,93 +592,160 @@ func encodeInstanceGroupType(t *string) int {
 type AppResizeOptions struct {
 	Name              string
 	InstanceGroupName string
-	Size              int
+	Size              string
+	Min               int
+	Max               int
 	Vars              map[string]string
-	Filename          string
-	DryRun            bool
+	TemplateOptions
+	DryRun              bool
+	Output              string
+	Explain             bool
+	Wait                bool
+	Timeout             time.Duration
+	Graceful            bool
+	DecommissionTimeout time.Duration
+	OnDemand            *int64 // target capacity of an instance fleet, nil to keep
+	Spot                *int64 // target capacity of an instance fleet, nil to keep
 }
 
 func (s *App) Resize(o *AppResizeOptions) error {
-	config, err := loadClusterConfigForResize(o.InstanceGroupName, o.Size, o.Filename, o.Name, o.Vars)
+	_, err := s.resize(o)
+	return err
+}
+
+// resize resizes the instance group or fleet, and returns the instance count requested for the instance group.
+// The count differs from the size given if it is clamped by the limits.
+func (s *App) resize(o *AppResizeOptions) (int64, error) {
+	filename, err := s.ConfigFile(o.Filename, o.Profile)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
-	if o.DryRun {
-		fmt.Fprintln(s.Stderr, "Resize cluster with:")
-		fmt.Fprintln(s.Stdout, config)
-		return nil
+	loader, err := s.newConfigLoader(o.Name, o.Vars, o.VarFiles)
+	if err != nil {
+		return 0, err
 	}
 
-	c, err := s.FindByName(o.Name)
+	config, fleet, err := loadClusterConfigForResize(loader, o.InstanceGroupName, filename)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	if fleet != nil {
+		return 0, s.resizeInstanceFleet(o, loader, fleet)
+	}
+	if o.OnDemand != nil || o.Spot != nil {
+		return 0, fmt.Errorf("--on-demand and --spot are only for instance fleets but %s is an instance group", o.InstanceGroupName)
 	}
 
-	ig, err := s.FindInstanceGroupByName(aws.StringValue(c.Id), o.InstanceGroupName)
+	spec, err := parseResizeSpec(o.Size)
 	if err != nil {
-		return err
+		return 0, err
 	}
 
+	// relative sizes need the current size even in dry-run
+	var c *emr.ClusterSummary
+	var ig *emr.InstanceGroup
+	if spec.IsRelative() || !o.DryRun {
+		c, err = s.FindByName(o.Name)
+		if err != nil {
+			return 0, err
+		}
+
+		ig, err = s.FindInstanceGroupByName(aws.StringValue(c.Id), o.InstanceGroupName)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	var current int64
+	if ig != nil {
+		current = aws.Int64Value(ig.RequestedInstanceCount)
+	}
+	min, max := resizeLimits(config, o.Min, o.Max)
+	size := clampSize(spec.Size(current), min, max)
+	config.InstanceCount = aws.Int64(size)
+
+	if o.Explain {
+		loader.Explain(s.Stderr)
+	}
+
+	if o.DryRun {
+		fmt.Fprintln(s.Stderr, "Resize cluster with:")
+		return size, loader.WriteConfig(s.Stdout, config, o.Output)
+	}
+
+	var igId *string
 	if ig == nil {
 		// Create new instance group
 		in := emr.AddInstanceGroupsInput{
 			JobFlowId:      c.Id,
 			InstanceGroups: []*emr.InstanceGroupConfig{config},
 		}
-		_, err := s.EMRAPI.AddInstanceGroups(&in)
+		out, err := s.EMRAPI.AddInstanceGroups(&in)
 		if err != nil {
-			return err
+			return 0, apiError("AddInstanceGroups", err)
+		}
+		if len(out.InstanceGroupIds) > 0 {
+			igId = out.InstanceGroupIds[0]
 		}
 	} else {
 		// Update existing instance group size
+		mod := &emr.InstanceGroupModifyConfig{
+			InstanceGroupId: ig.Id,
+			InstanceCount:   config.InstanceCount,
+		}
+		if o.Graceful && size < current {
+			mod.ShrinkPolicy, err = s.gracefulShrinkPolicy(aws.StringValue(c.Id), ig, size, o.DecommissionTimeout)
+			if err != nil {
+				return 0, err
+			}
+		}
+
 		in := emr.ModifyInstanceGroupsInput{
-			ClusterId: c.Id,
-			InstanceGroups: []*emr.InstanceGroupModifyConfig{
-				{
-					InstanceGroupId: ig.Id,
-					InstanceCount:   config.InstanceCount,
-				},
-			},
+			ClusterId:      c.Id,
+			InstanceGroups: []*emr.InstanceGroupModifyConfig{mod},
 		}
 		_, err := s.EMRAPI.ModifyInstanceGroups(&in)
 		if err != nil {
-			return err
+			return 0, apiError("ModifyInstanceGroups", err)
 		}
+		igId = ig.Id
 	}
 
-	fmt.Fprintf(s.Stderr, "resizing %s to %d...\n", o.InstanceGroupName, o.Size)
-	return nil
+	if spec.IsRelative() {
+		fmt.Fprintf(s.Stderr, "resizing %s from %d to %d...\n", o.InstanceGroupName, current, size)
+	} else {
+		fmt.Fprintf(s.Stderr, "resizing %s to %d...\n", o.InstanceGroupName, size)
+	}
+
+	// graceful shrink waits for the decommission to complete
+	if (o.Wait || o.Graceful) && igId != nil {
+		return size, s.WaitInstanceGroup(aws.StringValue(c.Id), aws.StringValue(igId), size, o.Timeout)
+	}
+	return size, nil
 }
 
-func loadClusterConfigForResize(instanceGroupName string, size int, filename string, name string, vars map[string]string) (*emr.InstanceGroupConfig, error) {
-	config, err := loadClusterConfig(filename, name, vars)
+// loadClusterConfigForResize returns the config of the instance group or the instance fleet with the name.
+func loadClusterConfigForResize(loader *configLoader, name string, filename string) (*emr.InstanceGroupConfig, *emr.InstanceFleetConfig, error) {
+	config, err := loader.LoadConfig(filename)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	for _, ig := range config.Instances.InstanceGroups {
-		if aws.StringValue(ig.Name) == instanceGroupName {
-			ig.InstanceCount = aws.Int64(int64(size))
-			return ig, nil
+		if aws.StringValue(ig.Name) == name {
+			return ig, nil, nil
 		}
 	}
 
-	return nil, fmt.Errorf("instance group %s is not found in configuration", instanceGroupName)
-}
-
-/*
- * Terminate cluster
- */
-func (s *App) Terminate(name string) error {
-	c, err := s.FindByName(name)
-	if err != nil {
-		return err
+	for _, f := range config.Instances.InstanceFleets {
+		if aws.StringValue(f.Name) == name {
+			return nil, f, nil
+		}
 	}
 
-	in := emr.TerminateJobFlowsInput{JobFlowIds: []*string{c.Id}}
-	_, err = s.EMRAPI.TerminateJobFlows(&in)
-	return err
+	return nil, nil, &NotFoundError{Kind: "instance group", Name: name}
 }
 
 /*
@@ -613,7 +852,7 @@ func (s *App) Shell(name string, args []string) error {
 
 	master, err := s.GetMaster(aws.StringValue(c.Id))
 	if err != nil {
-		return nil
+		return err
 	}
 
 	if len(args) == 0 {
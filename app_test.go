@@ -2,10 +2,14 @@ package main
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/service/emr"
 	"github.com/aws/aws-sdk-go/service/emr/emriface"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -38,6 +42,41 @@ type MockEMR struct {
 
 	LastTerminateJobFlowsInput *emr.TerminateJobFlowsInput
 	MockTerminateJobFlows      func(*emr.TerminateJobFlowsInput) (*emr.TerminateJobFlowsOutput, error)
+
+	LastListInstanceFleetsPagesInput *emr.ListInstanceFleetsInput
+	MockListInstanceFleetsPages      func(*emr.ListInstanceFleetsInput, func(*emr.ListInstanceFleetsOutput, bool) bool) error
+
+	LastListBootstrapActionsPagesInput *emr.ListBootstrapActionsInput
+	MockListBootstrapActionsPages      func(*emr.ListBootstrapActionsInput, func(*emr.ListBootstrapActionsOutput, bool) bool) error
+
+	LastListInstancesPagesInput *emr.ListInstancesInput
+	MockListInstancesPages      func(*emr.ListInstancesInput, func(*emr.ListInstancesOutput, bool) bool) error
+
+	LastAddInstanceFleetInput *emr.AddInstanceFleetInput
+	MockAddInstanceFleet      func(*emr.AddInstanceFleetInput) (*emr.AddInstanceFleetOutput, error)
+
+	LastModifyInstanceFleetInput *emr.ModifyInstanceFleetInput
+	MockModifyInstanceFleet      func(*emr.ModifyInstanceFleetInput) (*emr.ModifyInstanceFleetOutput, error)
+
+	LastGetManagedScalingPolicyInput *emr.GetManagedScalingPolicyInput
+	MockGetManagedScalingPolicy      func(*emr.GetManagedScalingPolicyInput) (*emr.GetManagedScalingPolicyOutput, error)
+
+	LastPutManagedScalingPolicyInput    *emr.PutManagedScalingPolicyInput
+	LastRemoveManagedScalingPolicyInput *emr.RemoveManagedScalingPolicyInput
+
+	PutAutoScalingPolicyInputs    []*emr.PutAutoScalingPolicyInput
+	RemoveAutoScalingPolicyInputs []*emr.RemoveAutoScalingPolicyInput
+
+	LastSetTerminationProtectionInput *emr.SetTerminationProtectionInput
+
+	LastWaitUntilClusterTerminatedInput *emr.DescribeClusterInput
+	MockWaitUntilClusterTerminated      func(*emr.DescribeClusterInput) error
+
+	LastPutAutoTerminationPolicyInput    *emr.PutAutoTerminationPolicyInput
+	LastRemoveAutoTerminationPolicyInput *emr.RemoveAutoTerminationPolicyInput
+	MockGetAutoTerminationPolicy         func(*emr.GetAutoTerminationPolicyInput) (*emr.GetAutoTerminationPolicyOutput, error)
+
+	MockListStepsPages func(*emr.ListStepsInput, func(*emr.ListStepsOutput, bool) bool) error
 }
 
 func (m *MockEMR) RunJobFlow(input *emr.RunJobFlowInput) (*emr.RunJobFlowOutput, error) {
@@ -148,15 +187,135 @@ func (m *MockEMR) TerminateJobFlows(input *emr.TerminateJobFlowsInput) (*emr.Ter
 	}
 }
 
+func (m *MockEMR) ListInstanceFleetsPages(input *emr.ListInstanceFleetsInput, fn func(*emr.ListInstanceFleetsOutput, bool) bool) error {
+	m.LastListInstanceFleetsPagesInput = input
+	if f := m.MockListInstanceFleetsPages; f != nil {
+		return f(input, fn)
+	} else {
+		fn(&emr.ListInstanceFleetsOutput{}, true)
+		return nil
+	}
+}
+
+func (m *MockEMR) ListBootstrapActionsPages(input *emr.ListBootstrapActionsInput, fn func(*emr.ListBootstrapActionsOutput, bool) bool) error {
+	m.LastListBootstrapActionsPagesInput = input
+	if f := m.MockListBootstrapActionsPages; f != nil {
+		return f(input, fn)
+	} else {
+		fn(&emr.ListBootstrapActionsOutput{}, true)
+		return nil
+	}
+}
+
+func (m *MockEMR) ListInstancesPages(input *emr.ListInstancesInput, fn func(*emr.ListInstancesOutput, bool) bool) error {
+	m.LastListInstancesPagesInput = input
+	if f := m.MockListInstancesPages; f != nil {
+		return f(input, fn)
+	} else {
+		fn(&emr.ListInstancesOutput{}, true)
+		return nil
+	}
+}
+
+func (m *MockEMR) AddInstanceFleet(input *emr.AddInstanceFleetInput) (*emr.AddInstanceFleetOutput, error) {
+	m.LastAddInstanceFleetInput = input
+	if f := m.MockAddInstanceFleet; f != nil {
+		return f(input)
+	} else {
+		return &emr.AddInstanceFleetOutput{InstanceFleetId: aws.String("if-00000009")}, nil
+	}
+}
+
+func (m *MockEMR) ModifyInstanceFleet(input *emr.ModifyInstanceFleetInput) (*emr.ModifyInstanceFleetOutput, error) {
+	m.LastModifyInstanceFleetInput = input
+	if f := m.MockModifyInstanceFleet; f != nil {
+		return f(input)
+	} else {
+		return &emr.ModifyInstanceFleetOutput{}, nil
+	}
+}
+
+func (m *MockEMR) WaitUntilClusterTerminated(input *emr.DescribeClusterInput) error {
+	m.LastWaitUntilClusterTerminatedInput = input
+	if f := m.MockWaitUntilClusterTerminated; f != nil {
+		return f(input)
+	} else {
+		return nil
+	}
+}
+
+func (m *MockEMR) PutAutoTerminationPolicy(input *emr.PutAutoTerminationPolicyInput) (*emr.PutAutoTerminationPolicyOutput, error) {
+	m.LastPutAutoTerminationPolicyInput = input
+	return &emr.PutAutoTerminationPolicyOutput{}, nil
+}
+
+func (m *MockEMR) RemoveAutoTerminationPolicy(input *emr.RemoveAutoTerminationPolicyInput) (*emr.RemoveAutoTerminationPolicyOutput, error) {
+	m.LastRemoveAutoTerminationPolicyInput = input
+	return &emr.RemoveAutoTerminationPolicyOutput{}, nil
+}
+
+func (m *MockEMR) GetAutoTerminationPolicy(input *emr.GetAutoTerminationPolicyInput) (*emr.GetAutoTerminationPolicyOutput, error) {
+	if f := m.MockGetAutoTerminationPolicy; f != nil {
+		return f(input)
+	} else {
+		return &emr.GetAutoTerminationPolicyOutput{}, nil
+	}
+}
+
+func (m *MockEMR) ListStepsPages(input *emr.ListStepsInput, fn func(*emr.ListStepsOutput, bool) bool) error {
+	if f := m.MockListStepsPages; f != nil {
+		return f(input, fn)
+	} else {
+		fn(&emr.ListStepsOutput{}, true)
+		return nil
+	}
+}
+
+func (m *MockEMR) GetManagedScalingPolicy(input *emr.GetManagedScalingPolicyInput) (*emr.GetManagedScalingPolicyOutput, error) {
+	m.LastGetManagedScalingPolicyInput = input
+	if f := m.MockGetManagedScalingPolicy; f != nil {
+		return f(input)
+	} else {
+		return &emr.GetManagedScalingPolicyOutput{}, nil
+	}
+}
+
+func (m *MockEMR) PutManagedScalingPolicy(input *emr.PutManagedScalingPolicyInput) (*emr.PutManagedScalingPolicyOutput, error) {
+	m.LastPutManagedScalingPolicyInput = input
+	return &emr.PutManagedScalingPolicyOutput{}, nil
+}
+
+func (m *MockEMR) RemoveManagedScalingPolicy(input *emr.RemoveManagedScalingPolicyInput) (*emr.RemoveManagedScalingPolicyOutput, error) {
+	m.LastRemoveManagedScalingPolicyInput = input
+	return &emr.RemoveManagedScalingPolicyOutput{}, nil
+}
+
+func (m *MockEMR) PutAutoScalingPolicy(input *emr.PutAutoScalingPolicyInput) (*emr.PutAutoScalingPolicyOutput, error) {
+	m.PutAutoScalingPolicyInputs = append(m.PutAutoScalingPolicyInputs, input)
+	return &emr.PutAutoScalingPolicyOutput{}, nil
+}
+
+func (m *MockEMR) RemoveAutoScalingPolicy(input *emr.RemoveAutoScalingPolicyInput) (*emr.RemoveAutoScalingPolicyOutput, error) {
+	m.RemoveAutoScalingPolicyInputs = append(m.RemoveAutoScalingPolicyInputs, input)
+	return &emr.RemoveAutoScalingPolicyOutput{}, nil
+}
+
+func (m *MockEMR) SetTerminationProtection(input *emr.SetTerminationProtectionInput) (*emr.SetTerminationProtectionOutput, error) {
+	m.LastSetTerminationProtectionInput = input
+	return &emr.SetTerminationProtectionOutput{}, nil
+}
+
 /*
  * Mock App
  */
 type MockApp struct {
 	App
 	EMRAPI    *MockEMR
+	Stdin     *bytes.Buffer
 	Stdout    *bytes.Buffer
 	Stderr    *bytes.Buffer
 	OpHandler *MockOperationHandle
+	Secrets   *MockSecretStore
 }
 
 type MockOperationHandle struct {
@@ -189,23 +348,70 @@ func (m *MockOperationHandle) Exec(args []string) error {
 	return nil
 }
 
+type MockSecretStore struct {
+	Parameters map[string]string
+	Secrets    map[string]string
+}
+
+func (m *MockSecretStore) GetParameter(name string) (string, error) {
+	if v, ok := m.Parameters[name]; ok {
+		return v, nil
+	}
+	return "", &NotFoundError{Kind: "parameter", Name: name}
+}
+
+func (m *MockSecretStore) GetSecretValue(id string) (string, error) {
+	if v, ok := m.Secrets[id]; ok {
+		return v, nil
+	}
+	return "", &NotFoundError{Kind: "secret", Name: id}
+}
+
 func NewMockApp() *MockApp {
 	m := &MockEMR{}
+	i := bytes.NewBufferString("")
 	o := bytes.NewBufferString("")
 	e := bytes.NewBufferString("")
 	h := &MockOperationHandle{}
+	sec := &MockSecretStore{}
 
 	return &MockApp{
 		App: App{
 			EMRAPI:    m,
+			Stdin:     i,
 			Stdout:    o,
 			Stderr:    e,
 			OpHandler: h,
+			Secrets:   sec,
+
+			Interactive: true,
 		},
 		EMRAPI:    m,
+		Stdin:     i,
 		Stdout:    o,
 		Stderr:    e,
 		OpHandler: h,
+		Secrets:   sec,
+	}
+}
+
+// mockClusters stubs ListClusters and DescribeCluster with the clusters, listed in the given order.
+func mockClusters(a *MockApp, clusters ...*emr.Cluster) {
+	a.EMRAPI.MockListClustersPages = func(input *emr.ListClustersInput, fn func(*emr.ListClustersOutput, bool) bool) error {
+		out := &emr.ListClustersOutput{}
+		for _, c := range clusters {
+			out.Clusters = append(out.Clusters, &emr.ClusterSummary{Id: c.Id, Name: c.Name, Status: c.Status})
+		}
+		fn(out, true)
+		return nil
+	}
+	a.EMRAPI.MockDescribeCluster = func(input *emr.DescribeClusterInput) (*emr.DescribeClusterOutput, error) {
+		for _, c := range clusters {
+			if aws.StringValue(c.Id) == aws.StringValue(input.ClusterId) {
+				return &emr.DescribeClusterOutput{Cluster: c}, nil
+			}
+		}
+		return nil, fmt.Errorf("cluster %s is not found", aws.StringValue(input.ClusterId))
 	}
 }
 
@@ -216,8 +422,8 @@ func TestStart(t *testing.T) {
 	a := NewMockApp()
 
 	err := a.Start(&AppStartOptions{
-		Name:     "test-cluster",
-		Filename: "./cluster-sample.yml",
+		Name:            "test-cluster",
+		TemplateOptions: TemplateOptions{Filename: "./cluster-sample.yml"},
 	})
 	if err != nil {
 		t.Fatalf("Start command expected to success but failed with %s", err.Error())
@@ -248,9 +454,9 @@ func TestStartWithSize(t *testing.T) {
 	a := NewMockApp()
 
 	err := a.Start(&AppStartOptions{
-		Name:     "test-cluster",
-		Filename: "./cluster-sample.yml",
-		Vars:     map[string]string{"core": "0"},
+		Name:            "test-cluster",
+		TemplateOptions: TemplateOptions{Filename: "./cluster-sample.yml"},
+		Vars:            map[string]string{"core": "0"},
 	})
 	if err != nil {
 		t.Fatalf("Start command expected to success but failed with %s", err.Error())
@@ -272,6 +478,86 @@ func TestStartWithSize(t *testing.T) {
 	}
 }
 
+func TestStartDryRun(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test-cluster",
+		TemplateOptions: TemplateOptions{Filename: "./cluster-sample.yml"},
+		Vars:            map[string]string{"core": "2"},
+		DryRun:          true,
+		Explain:         true,
+	})
+	if err != nil {
+		t.Fatalf("Start command expected to success but failed with %s", err.Error())
+	}
+
+	if a.EMRAPI.LastRunJobFlowInput != nil {
+		t.Errorf("RunJobFlow API is expected not to be called but called")
+	}
+
+	out := a.Stdout.String()
+	for _, exp := range []string{"name: test-cluster\n", "    instancecount: 2\n", "releaselabel: emr-5.9.0\n"} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("'%s' expected in '%s'", exp, out)
+		}
+	}
+	if strings.Contains(out, "null") {
+		t.Errorf("unset fields are expected to be omitted but got '%s'", out)
+	}
+
+	exp := "Variables:\n  core = 2 (argument)\nStart cluster with:\n"
+	if e := a.Stderr.String(); exp != e {
+		t.Errorf("'%s' expected but got '%s'", exp, e)
+	}
+}
+
+func TestStartDryRunJSON(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test-cluster",
+		TemplateOptions: TemplateOptions{Filename: "./cluster-sample.yml"},
+		DryRun:          true,
+		Output:          "json",
+	})
+	if err != nil {
+		t.Fatalf("Start command expected to success but failed with %s", err.Error())
+	}
+
+	// the skeleton of `aws emr create-cluster --cli-input-json`
+	out := struct {
+		Name            string
+		Ec2Attributes   map[string]string
+		InstanceGroups  []map[string]interface{}
+		Tags            []string
+		NoAutoTerminate bool
+		Instances       interface{}
+		JobFlowRole     interface{}
+	}{}
+	if err := json.Unmarshal(a.Stdout.Bytes(), &out); err != nil {
+		t.Fatalf("JSON output expected but got %s", err.Error())
+	}
+	if "test-cluster" != out.Name {
+		t.Errorf("test-cluster expected but got %v", out.Name)
+	}
+	if p := out.Ec2Attributes["InstanceProfile"]; "EMR_EC2_DefaultRole" != p {
+		t.Errorf("EMR_EC2_DefaultRole expected but got %v", p)
+	}
+	if len(out.InstanceGroups) != 2 || out.InstanceGroups[1]["InstanceGroupType"] != "CORE" {
+		t.Errorf("instance groups with InstanceGroupType expected but got %v", out.InstanceGroups)
+	}
+	if len(out.Tags) != 1 || "Name=EMR-test-cluster" != out.Tags[0] {
+		t.Errorf("tags as KEY=VALUE expected but got %v", out.Tags)
+	}
+	if !out.NoAutoTerminate {
+		t.Errorf("NoAutoTerminate expected")
+	}
+	if out.Instances != nil || out.JobFlowRole != nil {
+		t.Errorf("RunJobFlow request fields are expected not to be printed but got %s", a.Stdout.String())
+	}
+}
+
 /*
  * Test List
  */
@@ -330,8 +616,8 @@ func TestResizeNew(t *testing.T) {
 	err := a.Resize(&AppResizeOptions{
 		Name:              "test",
 		InstanceGroupName: "task",
-		Size:              2,
-		Filename:          "./cluster-sample.yml",
+		Size:              "2",
+		TemplateOptions:   TemplateOptions{Filename: "./cluster-sample.yml"},
 	})
 	if err != nil {
 		t.Fatalf("Resize command expected to success but failed with %s", err.Error())
@@ -370,8 +656,8 @@ func TestResizeExisting(t *testing.T) {
 	err := a.Resize(&AppResizeOptions{
 		Name:              "test",
 		InstanceGroupName: "core",
-		Size:              4,
-		Filename:          "./cluster-sample.yml",
+		Size:              "4",
+		TemplateOptions:   TemplateOptions{Filename: "./cluster-sample.yml"},
 	})
 	if err != nil {
 		t.Fatalf("Resize command expected to success but failed with %s", err.Error())
@@ -400,32 +686,6 @@ func TestResizeExisting(t *testing.T) {
 	}
 }
 
-/*
- * Test Terminate
- */
-func TestTerminate(t *testing.T) {
-	a := NewMockApp()
-
-	err := a.Terminate("test")
-	if err != nil {
-		t.Fatalf("Termiante command expected to success but failed with %s", err.Error())
-	}
-
-	input := a.EMRAPI.LastTerminateJobFlowsInput
-	if input == nil {
-		t.Fatalf("TermianteJobFlows API is expected to be caleld but not")
-	}
-
-	ids := input.JobFlowIds
-	if n := len(ids); 1 != n {
-		t.Fatalf("%d expected but got %d", 1, n)
-	}
-
-	if id := aws.StringValue(ids[0]); "j-00000000" != id {
-		t.Fatalf("'%s' expected but got '%s'", "j-00000000", id)
-	}
-}
-
 /*
  * Test SSH
  */
@@ -475,3 +735,86 @@ func TestSCP(t *testing.T) {
 		t.Errorf("%s expected but got %s", exp, input)
 	}
 }
+
+/*
+ * Test Shell
+ */
+func TestShell(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Shell("test", nil)
+	if err != nil {
+		t.Fatalf("Shell command expected to success but failed with %s", err.Error())
+	}
+
+	exp := "export EMR_MASTER=master-public-dns-name\n"
+	if out := a.Stdout.String(); exp != out {
+		t.Errorf("'%s' expected but got '%s'", exp, out)
+	}
+}
+
+func TestShellDescribeClusterError(t *testing.T) {
+	a := NewMockApp()
+	a.EMRAPI.MockDescribeCluster = func(*emr.DescribeClusterInput) (*emr.DescribeClusterOutput, error) {
+		return nil, errors.New("throttled")
+	}
+
+	err := a.Shell("test", nil)
+	if err == nil {
+		t.Fatalf("Shell command expected to fail but succeeded")
+	}
+	if code := exitCode(err); ExitCodeAPIError != code {
+		t.Errorf("exit code %d expected but got %d", ExitCodeAPIError, code)
+	}
+	if out := a.Stdout.String(); out != "" {
+		t.Errorf("nothing expected to be exported but got '%s'", out)
+	}
+}
+
+/*
+ * Test FindByName
+ */
+func TestFindByNameNotFound(t *testing.T) {
+	a := NewMockApp()
+
+	_, err := a.FindByName("unknown")
+	if code := exitCode(err); ExitCodeNotFound != code {
+		t.Errorf("exit code %d expected but got %d (%v)", ExitCodeNotFound, code, err)
+	}
+}
+
+func TestFindByNameAmbiguous(t *testing.T) {
+	a := NewMockApp()
+	a.EMRAPI.MockListClustersPages = func(input *emr.ListClustersInput, fn func(*emr.ListClustersOutput, bool) bool) error {
+		fn(&emr.ListClustersOutput{
+			Clusters: []*emr.ClusterSummary{
+				{Id: aws.String("j-00000001"), Name: aws.String("test")},
+				{Id: aws.String("j-00000002"), Name: aws.String("test")},
+			},
+		}, true)
+		return nil
+	}
+
+	_, err := a.FindByName("test")
+	if code := exitCode(err); ExitCodeAmbiguous != code {
+		t.Errorf("exit code %d expected but got %d (%v)", ExitCodeAmbiguous, code, err)
+	}
+}
+
+/*
+ * Test confirm
+ */
+func TestConfirmPipedAnswers(t *testing.T) {
+	a := NewMockApp()
+	a.Stdin.WriteString("y\nn\nyes\n")
+
+	for i, exp := range []bool{true, false, true, false} {
+		ok, err := a.confirm("OK?")
+		if err != nil {
+			t.Fatalf("confirm expected to success but failed with %s", err.Error())
+		}
+		if exp != ok {
+			t.Errorf("answer %d: %v expected but got %v", i, exp, ok)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"io"
+)
+
+/*
+ * Apply instance group sizes
+ */
+
+// InstanceGroupChange is a step of the plan to resize the cluster to the template.
+// InstanceGroupId is nil if the group is added.
+type InstanceGroupChange struct {
+	Name            string
+	InstanceGroupId *string
+	From            int64
+	To              int64
+	Config          *emr.InstanceGroupConfig
+}
+
+func (c *InstanceGroupChange) String() string {
+	if c.InstanceGroupId == nil {
+		return fmt.Sprintf("+ %s: add %d %s instances", c.Name, c.To, aws.StringValue(c.Config.InstanceType))
+	}
+	return fmt.Sprintf("~ %s: %d => %d", c.Name, c.From, c.To)
+}
+
+type AppApplyOptions struct {
+	Name string
+	Vars map[string]string
+	TemplateOptions
+	AutoApprove bool
+}
+
+// Apply resizes all the instance groups of the cluster to the counts in the template.
+func (s *App) Apply(o *AppApplyOptions) error {
+	filename, err := s.ConfigFile(o.Filename, o.Profile)
+	if err != nil {
+		return err
+	}
+
+	loader, err := s.newConfigLoader(o.Name, o.Vars, o.VarFiles)
+	if err != nil {
+		return err
+	}
+
+	config, err := loader.LoadConfig(filename)
+	if err != nil {
+		return err
+	}
+
+	id, err := s.FindClusterId(o.Name)
+	if err != nil {
+		return err
+	}
+
+	plan, err := s.planInstanceGroups(id, config.Instances.InstanceGroups)
+	if err != nil {
+		return err
+	}
+
+	if len(plan) == 0 {
+		fmt.Fprintf(s.Stderr, "%s (%s) is up to date with %s\n", o.Name, id, filename)
+		return nil
+	}
+
+	fmt.Fprintf(s.Stdout, "Plan for %s (%s):\n", o.Name, id)
+	writePlan(s.Stdout, plan)
+
+	if !o.AutoApprove {
+		// never skip the plan silently, e.g. in CI
+		if !s.Interactive {
+			return fmt.Errorf("stdin is not a terminal: use --auto-approve to apply the plan without confirmation")
+		}
+		ok, err := s.confirm("Apply the plan?")
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("canceled: nothing is applied")
+		}
+	}
+
+	return s.applyPlan(id, plan)
+}
+
+// planInstanceGroups compares the instance groups of the cluster with the configs.
+// Groups missing in the configs are left as they are, and MASTER groups cannot be resized.
+func (s *App) planInstanceGroups(id string, configs []*emr.InstanceGroupConfig) ([]*InstanceGroupChange, error) {
+	in := emr.ListInstanceGroupsInput{ClusterId: aws.String(id)}
+	current := map[string]*emr.InstanceGroup{}
+	err := s.EMRAPI.ListInstanceGroupsPages(&in, func(out *emr.ListInstanceGroupsOutput, b bool) bool {
+		for _, ig := range out.InstanceGroups {
+			current[aws.StringValue(ig.Name)] = ig
+		}
+		return true
+	})
+	if err != nil {
+		return nil, apiError("ListInstanceGroups", err)
+	}
+
+	var plan []*InstanceGroupChange
+	for _, config := range configs {
+		name := aws.StringValue(config.Name)
+		size := aws.Int64Value(config.InstanceCount)
+		if aws.StringValue(config.InstanceRole) == emr.InstanceRoleTypeMaster {
+			continue
+		}
+
+		ig, ok := current[name]
+		if !ok {
+			if size > 0 {
+				plan = append(plan, &InstanceGroupChange{Name: name, To: size, Config: config})
+			}
+			continue
+		}
+
+		if n := aws.Int64Value(ig.RequestedInstanceCount); n != size {
+			plan = append(plan, &InstanceGroupChange{Name: name, InstanceGroupId: ig.Id, From: n, To: size, Config: config})
+		}
+	}
+	return plan, nil
+}
+
+func (s *App) applyPlan(id string, plan []*InstanceGroupChange) error {
+	var adds []*emr.InstanceGroupConfig
+	var mods []*emr.InstanceGroupModifyConfig
+	for _, c := range plan {
+		if c.InstanceGroupId == nil {
+			adds = append(adds, c.Config)
+		} else {
+			mods = append(mods, &emr.InstanceGroupModifyConfig{
+				InstanceGroupId: c.InstanceGroupId,
+				InstanceCount:   aws.Int64(c.To),
+			})
+		}
+	}
+
+	if len(adds) > 0 {
+		in := emr.AddInstanceGroupsInput{
+			JobFlowId:      aws.String(id),
+			InstanceGroups: adds,
+		}
+		_, err := s.EMRAPI.AddInstanceGroups(&in)
+		if err != nil {
+			return apiError("AddInstanceGroups", err)
+		}
+	}
+
+	if len(mods) > 0 {
+		in := emr.ModifyInstanceGroupsInput{
+			ClusterId:      aws.String(id),
+			InstanceGroups: mods,
+		}
+		_, err := s.EMRAPI.ModifyInstanceGroups(&in)
+		if err != nil {
+			return apiError("ModifyInstanceGroups", err)
+		}
+	}
+
+	for _, c := range plan {
+		fmt.Fprintf(s.Stderr, "resizing %s to %d...\n", c.Name, c.To)
+	}
+	return nil
+}
+
+func writePlan(w io.Writer, plan []*InstanceGroupChange) {
+	for _, c := range plan {
+		fmt.Fprintf(w, "  %s\n", c)
+	}
+}
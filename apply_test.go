@@ -0,0 +1,163 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"strings"
+	"testing"
+)
+
+func mockInstanceGroups(a *MockApp) {
+	a.EMRAPI.MockListInstanceGroupsPages = func(input *emr.ListInstanceGroupsInput, fn func(*emr.ListInstanceGroupsOutput, bool) bool) error {
+		fn(&emr.ListInstanceGroupsOutput{
+			InstanceGroups: []*emr.InstanceGroup{
+				{
+					Id:                     aws.String("ig-00000001"),
+					Name:                   aws.String("master"),
+					InstanceGroupType:      aws.String(emr.InstanceGroupTypeMaster),
+					RequestedInstanceCount: aws.Int64(1),
+				},
+				{
+					Id:                     aws.String("ig-00000002"),
+					Name:                   aws.String("core"),
+					InstanceGroupType:      aws.String(emr.InstanceGroupTypeCore),
+					RequestedInstanceCount: aws.Int64(5),
+				},
+				{
+					Id:                     aws.String("ig-00000003"),
+					Name:                   aws.String("task"),
+					InstanceGroupType:      aws.String(emr.InstanceGroupTypeTask),
+					RequestedInstanceCount: aws.Int64(2),
+				},
+			},
+		}, true)
+		return nil
+	}
+}
+
+/*
+ * Test Apply
+ */
+func TestApply(t *testing.T) {
+	a := NewMockApp()
+	mockInstanceGroups(a)
+
+	err := a.Apply(&AppApplyOptions{
+		Name:            "test",
+		Vars:            map[string]string{"name": "test", "core": "3", "spot": "2"},
+		TemplateOptions: TemplateOptions{Filename: "./testdata/apply/cluster.yml"},
+		AutoApprove:     true,
+	})
+	if err != nil {
+		t.Fatalf("Apply command expected to success but failed with %s", err.Error())
+	}
+
+	exp := strings.Join([]string{
+		"Plan for test (j-00000000):",
+		"  ~ core: 5 => 3",
+		"  ~ task: 2 => 0",
+		"  + spot: add 2 m3.xlarge instances",
+		"",
+	}, "\n")
+	if out := a.Stdout.String(); exp != out {
+		t.Errorf("'%s' expected but got '%s'", exp, out)
+	}
+
+	adds := a.EMRAPI.LastAddInstanceGroupsInput.InstanceGroups
+	if n := len(adds); 1 != n {
+		t.Fatalf("1 instance group expected to be added but got %d", n)
+	}
+	if name := aws.StringValue(adds[0].Name); "spot" != name {
+		t.Errorf("spot expected but got %s", name)
+	}
+
+	mods := a.EMRAPI.LastModifyInstanceGroupsInput.InstanceGroups
+	if n := len(mods); 2 != n {
+		t.Fatalf("2 instance groups expected to be modified but got %d", n)
+	}
+	if id, n := aws.StringValue(mods[1].InstanceGroupId), aws.Int64Value(mods[1].InstanceCount); "ig-00000003" != id || 0 != n {
+		t.Errorf("ig-00000003 expected to be resized to 0 but got %s to %d", id, n)
+	}
+}
+
+func TestApplyCanceled(t *testing.T) {
+	a := NewMockApp()
+	mockInstanceGroups(a)
+	a.Stdin.WriteString("n\n")
+
+	err := a.Apply(&AppApplyOptions{
+		Name:            "test",
+		Vars:            map[string]string{"name": "test", "core": "3"},
+		TemplateOptions: TemplateOptions{Filename: "./testdata/apply/cluster.yml"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "canceled") {
+		t.Fatalf("Apply command expected to be canceled but got %v", err)
+	}
+
+	if a.EMRAPI.LastModifyInstanceGroupsInput != nil {
+		t.Errorf("ModifyInstanceGroups API is expected not to be called")
+	}
+	if msg := a.Stderr.String(); !strings.Contains(msg, "Apply the plan? [y/N] ") {
+		t.Errorf("confirmation expected but got '%s'", msg)
+	}
+}
+
+func TestApplyNotInteractive(t *testing.T) {
+	a := NewMockApp()
+	mockInstanceGroups(a)
+	a.Interactive = false
+	a.Stdin.WriteString("y\n")
+
+	err := a.Apply(&AppApplyOptions{
+		Name:            "test",
+		Vars:            map[string]string{"name": "test", "core": "3"},
+		TemplateOptions: TemplateOptions{Filename: "./testdata/apply/cluster.yml"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "use --auto-approve") {
+		t.Fatalf("Apply command expected to require --auto-approve but got %v", err)
+	}
+
+	if a.EMRAPI.LastModifyInstanceGroupsInput != nil {
+		t.Errorf("ModifyInstanceGroups API is expected not to be called")
+	}
+}
+
+func TestApplyConfirmed(t *testing.T) {
+	a := NewMockApp()
+	mockInstanceGroups(a)
+	a.Stdin.WriteString("yes\n")
+
+	err := a.Apply(&AppApplyOptions{
+		Name:            "test",
+		Vars:            map[string]string{"name": "test", "core": "3"},
+		TemplateOptions: TemplateOptions{Filename: "./testdata/apply/cluster.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Apply command expected to success but failed with %s", err.Error())
+	}
+
+	if a.EMRAPI.LastModifyInstanceGroupsInput == nil {
+		t.Errorf("ModifyInstanceGroups API is expected to be called")
+	}
+	if a.EMRAPI.LastAddInstanceGroupsInput != nil {
+		t.Errorf("AddInstanceGroups API is expected not to be called")
+	}
+}
+
+func TestApplyUpToDate(t *testing.T) {
+	a := NewMockApp()
+	mockInstanceGroups(a)
+
+	err := a.Apply(&AppApplyOptions{
+		Name:            "test",
+		Vars:            map[string]string{"name": "test", "task": "2"},
+		TemplateOptions: TemplateOptions{Filename: "./testdata/apply/cluster.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Apply command expected to success but failed with %s", err.Error())
+	}
+
+	if out := a.Stdout.String(); out != "" {
+		t.Errorf("no plan expected but got '%s'", out)
+	}
+}
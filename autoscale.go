@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"strconv"
+	"time"
+)
+
+/*
+ * Autoscale instance group by YARN metrics
+ *
+ * The autoscaler polls the ResourceManager metrics and resizes the group by Step:
+ *   - out when containers are pending for Checks polls in a row
+ *   - in when nothing is pending and memory used is below ScaleInMemory for Checks polls in a row
+ * No decision is made within Cooldown after a resize.
+ */
+type AppAutoscaleOptions struct {
+	Name              string
+	InstanceGroupName string
+	Min               int
+	Max               int
+	Step              int
+	ScaleInMemory     int // memory used (%) under which the group shrinks
+	Checks            int // consecutive polls required for a decision
+	Interval          time.Duration
+	Cooldown          time.Duration
+	Iterations        int // number of polls, 0 to run forever
+	DryRun            bool
+	Vars              map[string]string
+	TemplateOptions
+}
+
+// autoscaler keeps the state of the control loop between polls.
+type autoscaler struct {
+	app        *App
+	opts       *AppAutoscaleOptions
+	clusterId  string // id of the cluster once found
+	done       bool   // the cluster has terminated
+	outStreak  int
+	inStreak   int
+	lastResize time.Time
+}
+
+// Autoscale runs the control loop until Iterations polls are done.
+func (s *App) Autoscale(o *AppAutoscaleOptions) error {
+	if o.Max < o.Min {
+		return fmt.Errorf("--max %d is less than --min %d", o.Max, o.Min)
+	}
+
+	a := &autoscaler{app: s, opts: o}
+	for i := 0; o.Iterations == 0 || i < o.Iterations; i++ {
+		if i > 0 {
+			time.Sleep(o.Interval)
+		}
+		err := a.step(time.Now())
+		if err != nil {
+			return err
+		}
+		if a.done {
+			break
+		}
+	}
+	return nil
+}
+
+// step polls the metrics once and resizes the group if needed.
+// Errors finding the cluster, reading the metrics and resizing are logged and retried at the next poll.
+// A name never found is an error, and the loop ends when the cluster has terminated.
+func (a *autoscaler) step(now time.Time) error {
+	s, o := a.app, a.opts
+
+	c, err := s.FindByName(o.Name)
+	if err != nil {
+		_, notFound := err.(*NotFoundError)
+		if notFound && a.clusterId == "" {
+			return err
+		}
+		if notFound && a.terminated() {
+			a.log(now, "%s (%s) has terminated", o.Name, a.clusterId)
+			a.done = true
+			return nil
+		}
+		a.log(now, "skip: %s", err.Error())
+		return nil
+	}
+	id := aws.StringValue(c.Id)
+	a.clusterId = id
+
+	ig, err := s.FindInstanceGroupByName(id, o.InstanceGroupName)
+	if err != nil {
+		a.log(now, "skip: %s", err.Error())
+		return nil
+	}
+	var current int64
+	if ig != nil {
+		current = aws.Int64Value(ig.RequestedInstanceCount)
+	}
+
+	master, err := s.GetMaster(id)
+	if err != nil {
+		a.log(now, "skip: %s", err.Error())
+		return nil
+	}
+	m, err := s.getClusterMetrics(fmt.Sprintf("http://%s:8088/ws/v1/cluster/metrics", master))
+	if err != nil {
+		a.log(now, "skip: %s", err.Error())
+		return nil
+	}
+	// memory used is unknown, which must not count as idle
+	if m.TotalMB == 0 {
+		a.log(now, "skip: no NodeManagers")
+		return nil
+	}
+
+	status := fmt.Sprintf("%s=%d pending=%d memory=%d%%", o.InstanceGroupName, current, m.ContainersPending, m.MemoryUsed)
+	target, reason := a.decide(now, current, m)
+	if target == current {
+		a.log(now, "%s: keep: %s", status, reason)
+		return nil
+	}
+
+	if o.DryRun {
+		a.log(now, "%s: resize to %d (dry-run): %s", status, target, reason)
+	} else {
+		// --min and --max override the constraints of the autoscalingpolicy in the template
+		size, err := s.resize(&AppResizeOptions{
+			Name:              o.Name,
+			InstanceGroupName: o.InstanceGroupName,
+			Size:              strconv.FormatInt(target, 10),
+			Min:               o.Min,
+			Max:               o.Max,
+			Vars:              o.Vars,
+			TemplateOptions:   o.TemplateOptions,
+		})
+		if err != nil {
+			a.log(now, "%s: resize to %d failed: %s", status, target, err.Error())
+			return nil
+		}
+		if size != target {
+			a.log(now, "%s: resize to %d (%d clamped by the template): %s", status, size, target, reason)
+		} else {
+			a.log(now, "%s: resize to %d: %s", status, size, reason)
+		}
+	}
+
+	a.lastResize = now
+	a.outStreak, a.inStreak = 0, 0
+	return nil
+}
+
+// decide returns the new size and the reason.
+func (a *autoscaler) decide(now time.Time, current int64, m *ClusterMetrics) (int64, string) {
+	o := a.opts
+	min, max := int64(o.Min), int64(o.Max)
+
+	// keep the group within the limits regardless of the metrics
+	if current < min {
+		return min, fmt.Sprintf("below --min %d", min)
+	}
+	if current > max {
+		return max, fmt.Sprintf("above --max %d", max)
+	}
+
+	if m.ContainersPending > 0 {
+		a.outStreak, a.inStreak = a.outStreak+1, 0
+	} else if m.MemoryUsed < o.ScaleInMemory {
+		a.outStreak, a.inStreak = 0, a.inStreak+1
+	} else {
+		a.outStreak, a.inStreak = 0, 0
+		return current, "load is stable"
+	}
+
+	if d := now.Sub(a.lastResize); !a.lastResize.IsZero() && d < o.Cooldown {
+		return current, fmt.Sprintf("cooldown for %s", (o.Cooldown - d).String())
+	}
+
+	if a.outStreak > 0 {
+		if a.outStreak < o.Checks {
+			return current, fmt.Sprintf("containers pending %d/%d checks", a.outStreak, o.Checks)
+		}
+		if current >= max {
+			return current, fmt.Sprintf("containers pending but at --max %d", max)
+		}
+		return clampSize(current+int64(o.Step), min, max),
+			fmt.Sprintf("%d containers pending for %d checks", m.ContainersPending, a.outStreak)
+	}
+
+	if a.inStreak < o.Checks {
+		return current, fmt.Sprintf("memory used under %d%% %d/%d checks", o.ScaleInMemory, a.inStreak, o.Checks)
+	}
+	if current <= min {
+		return current, fmt.Sprintf("idle but at --min %d", min)
+	}
+	return clampSize(current-int64(o.Step), min, max),
+		fmt.Sprintf("memory used under %d%% for %d checks", o.ScaleInMemory, a.inStreak)
+}
+
+// terminated returns true if the cluster once found is terminating or terminated.
+func (a *autoscaler) terminated() bool {
+	out, err := a.app.EMRAPI.DescribeCluster(&emr.DescribeClusterInput{ClusterId: aws.String(a.clusterId)})
+	if err != nil || out.Cluster.Status == nil {
+		return false
+	}
+	switch aws.StringValue(out.Cluster.Status.State) {
+	case emr.ClusterStateTerminating, emr.ClusterStateTerminated, emr.ClusterStateTerminatedWithErrors:
+		return true
+	}
+	return false
+}
+
+func (a *autoscaler) log(now time.Time, format string, args ...interface{}) {
+	fmt.Fprintf(a.app.Stderr, "%s %s\n", now.Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
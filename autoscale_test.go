@@ -0,0 +1,246 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockMetrics serves the YARN cluster metrics in order, repeating the last one.
+func mockMetrics(a *MockApp, metrics ...[2]int) {
+	i := 0
+	a.OpHandler.MockGet = func(url string) ([]byte, error) {
+		m := metrics[i]
+		if i < len(metrics)-1 {
+			i++
+		}
+		return []byte(fmt.Sprintf(`{"clusterMetrics": {"containersPending": %d, "allocatedMB": %d, "totalMB": 100}}`, m[0], m[1])), nil
+	}
+}
+
+func newAutoscaler(a *MockApp, dryRun bool) *autoscaler {
+	return &autoscaler{
+		app: &a.App,
+		opts: &AppAutoscaleOptions{
+			Name:              "test",
+			InstanceGroupName: "task",
+			Min:               0,
+			Max:               3,
+			Step:              2,
+			ScaleInMemory:     50,
+			Checks:            2,
+			Cooldown:          10 * time.Minute,
+			DryRun:            dryRun,
+			TemplateOptions:   TemplateOptions{Filename: "./cluster-sample.yml"},
+		},
+	}
+}
+
+/*
+ * Test Autoscale
+ */
+func TestAutoscaleOut(t *testing.T) {
+	a := NewMockApp()
+	mockMetrics(a, [2]int{80, 90})
+	as := newAutoscaler(a, false)
+	now := time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if err := as.step(now.Add(time.Duration(i) * time.Minute)); err != nil {
+			t.Fatalf("autoscale expected to success but failed with %s", err.Error())
+		}
+	}
+
+	input := a.EMRAPI.LastAddInstanceGroupsInput
+	if input == nil {
+		t.Fatalf("AddInstanceGroups API is expected to be called")
+	}
+	if n := aws.Int64Value(input.InstanceGroups[0].InstanceCount); 2 != n {
+		t.Errorf("2 expected but got %d", n)
+	}
+
+	msg := a.Stderr.String()
+	for _, exp := range []string{
+		"2026-10-18T01:00:00Z task=0 pending=80 memory=90%: keep: containers pending 1/2 checks",
+		"2026-10-18T01:01:00Z task=0 pending=80 memory=90%: resize to 2: 80 containers pending for 2 checks",
+		"2026-10-18T01:02:00Z task=0 pending=80 memory=90%: keep: cooldown for 9m0s",
+	} {
+		if !strings.Contains(msg, exp) {
+			t.Errorf("'%s' expected in '%s'", exp, msg)
+		}
+	}
+}
+
+func TestAutoscaleMaxOverridesTemplate(t *testing.T) {
+	a := NewMockApp()
+	mockMetrics(a, [2]int{80, 90})
+	as := newAutoscaler(a, false)
+	as.opts.Filename = "./testdata/autoscale/cluster.yml"
+	as.opts.Max = 6
+	as.opts.Step = 4
+	now := time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if err := as.step(now.Add(time.Duration(i) * time.Minute)); err != nil {
+			t.Fatalf("autoscale expected to success but failed with %s", err.Error())
+		}
+	}
+
+	// maxcapacity 2 of the template is overridden by --max
+	input := a.EMRAPI.LastAddInstanceGroupsInput
+	if input == nil {
+		t.Fatalf("AddInstanceGroups API is expected to be called")
+	}
+	if n := aws.Int64Value(input.InstanceGroups[0].InstanceCount); 4 != n {
+		t.Errorf("4 expected but got %d", n)
+	}
+	exp := "task=0 pending=80 memory=90%: resize to 4: 80 containers pending for 2 checks"
+	if msg := a.Stderr.String(); !strings.Contains(msg, exp) {
+		t.Errorf("'%s' expected in '%s'", exp, msg)
+	}
+}
+
+func TestAutoscaleNoNodeManagers(t *testing.T) {
+	a := NewMockApp()
+	as := newAutoscaler(a, false)
+	as.opts.InstanceGroupName = "core"
+	as.opts.Max = 10
+	a.OpHandler.MockGet = func(url string) ([]byte, error) {
+		return []byte(`{"clusterMetrics": {"containersPending": 0, "allocatedMB": 0, "totalMB": 0}}`), nil
+	}
+	now := time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if err := as.step(now.Add(time.Duration(i) * time.Minute)); err != nil {
+			t.Fatalf("autoscale expected to success but failed with %s", err.Error())
+		}
+	}
+
+	if a.EMRAPI.LastModifyInstanceGroupsInput != nil {
+		t.Errorf("ModifyInstanceGroups API is expected not to be called without NodeManagers")
+	}
+	if as.inStreak != 0 {
+		t.Errorf("polls without NodeManagers expected not to count as idle but got %d", as.inStreak)
+	}
+	exp := "2026-10-18T01:02:00Z skip: no NodeManagers"
+	if msg := a.Stderr.String(); !strings.Contains(msg, exp) {
+		t.Errorf("'%s' expected in '%s'", exp, msg)
+	}
+}
+
+func TestAutoscaleInDryRun(t *testing.T) {
+	a := NewMockApp()
+	mockMetrics(a, [2]int{0, 10})
+	as := newAutoscaler(a, true)
+	as.opts.InstanceGroupName = "core"
+	as.opts.Max = 10
+	now := time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 2; i++ {
+		if err := as.step(now.Add(time.Duration(i) * time.Minute)); err != nil {
+			t.Fatalf("autoscale expected to success but failed with %s", err.Error())
+		}
+	}
+
+	if a.EMRAPI.LastModifyInstanceGroupsInput != nil {
+		t.Errorf("ModifyInstanceGroups API is expected not to be called in dry-run")
+	}
+	exp := "core=5 pending=0 memory=10%: resize to 3 (dry-run): memory used under 50% for 2 checks"
+	if msg := a.Stderr.String(); !strings.Contains(msg, exp) {
+		t.Errorf("'%s' expected in '%s'", exp, msg)
+	}
+}
+
+func TestAutoscaleHysteresis(t *testing.T) {
+	a := NewMockApp()
+	mockMetrics(a, [2]int{80, 90}, [2]int{0, 70}, [2]int{80, 90})
+	as := newAutoscaler(a, false)
+	now := time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if err := as.step(now.Add(time.Duration(i) * time.Minute)); err != nil {
+			t.Fatalf("autoscale expected to success but failed with %s", err.Error())
+		}
+	}
+
+	if a.EMRAPI.LastAddInstanceGroupsInput != nil {
+		t.Errorf("AddInstanceGroups API is expected not to be called when the load is not sustained")
+	}
+	if msg := a.Stderr.String(); !strings.Contains(msg, "keep: load is stable") {
+		t.Errorf("stable load expected in '%s'", msg)
+	}
+}
+
+func TestAutoscaleRetriesListErrors(t *testing.T) {
+	a := NewMockApp()
+	mockMetrics(a, [2]int{0, 90})
+	as := newAutoscaler(a, true)
+	now := time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)
+
+	// the first poll finds the cluster, then ListClusters is throttled and the cluster is briefly missing
+	for i, list := range []error{nil, fmt.Errorf("Throttling: Rate exceeded"), nil} {
+		if i > 0 {
+			err := list
+			a.EMRAPI.MockListClustersPages = func(input *emr.ListClustersInput, fn func(*emr.ListClustersOutput, bool) bool) error {
+				if err != nil {
+					return err
+				}
+				fn(&emr.ListClustersOutput{}, true)
+				return nil
+			}
+		}
+		if err := as.step(now.Add(time.Duration(i) * time.Minute)); err != nil {
+			t.Fatalf("autoscale expected to success but failed with %s", err.Error())
+		}
+	}
+	if as.done {
+		t.Errorf("autoscale expected to keep running while the cluster is not terminated")
+	}
+
+	msg := a.Stderr.String()
+	for _, exp := range []string{
+		"2026-10-18T01:01:00Z skip: ListClusters failed: Throttling: Rate exceeded",
+		"2026-10-18T01:02:00Z skip: cluster test is not found",
+	} {
+		if !strings.Contains(msg, exp) {
+			t.Errorf("'%s' expected in '%s'", exp, msg)
+		}
+	}
+}
+
+func TestAutoscaleClusterTerminated(t *testing.T) {
+	a := NewMockApp()
+	mockMetrics(a, [2]int{0, 90})
+	as := newAutoscaler(a, true)
+	as.clusterId = "j-00000000"
+	a.EMRAPI.MockListClustersPages = func(input *emr.ListClustersInput, fn func(*emr.ListClustersOutput, bool) bool) error {
+		fn(&emr.ListClustersOutput{}, true)
+		return nil
+	}
+	a.EMRAPI.MockDescribeCluster = func(input *emr.DescribeClusterInput) (*emr.DescribeClusterOutput, error) {
+		return &emr.DescribeClusterOutput{Cluster: &emr.Cluster{
+			Status: &emr.ClusterStatus{State: aws.String(emr.ClusterStateTerminated)},
+		}}, nil
+	}
+
+	if err := as.step(time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("autoscale expected to success but failed with %s", err.Error())
+	}
+	if !as.done {
+		t.Errorf("autoscale expected to end after the cluster terminated")
+	}
+}
+
+func TestAutoscaleUnknownCluster(t *testing.T) {
+	a := NewMockApp()
+	as := newAutoscaler(a, true)
+	as.opts.Name = "unknown"
+
+	err := as.step(time.Now())
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Errorf("NotFoundError expected for a cluster never found but got %v", err)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"gopkg.in/urfave/cli.v1"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+// ClusterNameCacheTTL is how long the cluster names fetched for completion are reused.
+var ClusterNameCacheTTL = 60 * time.Second
+
+/*
+ * INIT command
+ */
+type AppInitOptions struct {
+	Name  string
+	Shell string
+}
+
+func (s *App) Init(o *AppInitOptions) error {
+	name := o.Name
+	if name == "" {
+		name = "emrcmd"
+	}
+
+	var script string
+	switch o.Shell {
+	case "", "bash":
+		script = initScriptPOSIX(name) + initCompletionBash(name)
+	case "zsh":
+		script = initScriptPOSIX(name) + initCompletionZsh(name)
+	case "fish":
+		script = initScriptFish(name)
+	default:
+		return fmt.Errorf("unsupported shell %s (bash, zsh or fish expected)", o.Shell)
+	}
+
+	fmt.Fprintln(s.Stdout, script)
+	return nil
+}
+
+func initScriptPOSIX(name string) string {
+	return name + `() {
+  local command out
+  command="$1"
+  if [ "$#" -gt 0 ]; then
+    shift
+  fi
+
+  case "$command" in
+  shell)
+    if [ "$#" -eq 1 ]; then
+      out=$(command emrcmd shell "$@") || return $?
+      eval "$out"
+    else
+      command emrcmd shell "$@"
+    fi
+    ;;
+  *)
+    command emrcmd "$command" "$@";;
+  esac
+}
+`
+}
+
+func initCompletionBash(name string) string {
+	fn := completionFuncName(name)
+	return `
+` + fn + `() {
+  local cur opts
+  COMPREPLY=()
+  cur="${COMP_WORDS[COMP_CWORD]}"
+  opts=$(command emrcmd "${COMP_WORDS[@]:1:$((COMP_CWORD-1))}" --generate-bash-completion 2>/dev/null)
+  COMPREPLY=( $(compgen -W "${opts}" -- "${cur}") )
+  return 0
+}
+complete -F ` + fn + ` ` + name + `
+`
+}
+
+func initCompletionZsh(name string) string {
+	fn := completionFuncName(name)
+	return `
+` + fn + `() {
+  local -a opts
+  opts=("${(@f)$(command emrcmd "${(@)words[2,CURRENT-1]}" --generate-bash-completion 2>/dev/null)}")
+  compadd -a opts
+}
+if (( $+functions[compdef] )); then
+  compdef ` + fn + ` ` + name + `
+fi
+`
+}
+
+func initScriptFish(name string) string {
+	fn := completionFuncName(name)
+	return `function ` + name + `
+  if test (count $argv) -eq 2; and test "$argv[1]" = shell
+    set -l out (command emrcmd shell $argv[2])
+    set -l code $status
+    if test $code -ne 0
+      return $code
+    end
+    eval $out
+  else
+    command emrcmd $argv
+  end
+end
+
+function ` + fn + `
+  set -l args (commandline -opc)
+  command emrcmd $args[2..-1] --generate-bash-completion 2>/dev/null
+end
+complete -c ` + name + ` -f -a '(` + fn + `)'
+`
+}
+
+func completionFuncName(name string) string {
+	return "_" + strings.Replace(name, "-", "_", -1) + "_complete"
+}
+
+/*
+ * Completion helpers
+ */
+
+// CompleteClusterNames returns the names of active clusters.
+// The result is cached under CacheDir for ClusterNameCacheTTL so that completion stays responsive.
+// The cache is kept per AWS profile and region, which list different clusters.
+func (s *App) CompleteClusterNames() ([]string, error) {
+	var cache string
+	if s.CacheDir != "" {
+		key := strings.Replace(s.AWSProfile+"."+s.AWSRegion, string(os.PathSeparator), "_", -1)
+		cache = path.Join(s.CacheDir, "cluster-names."+key)
+		if names, ok := readNameCache(cache); ok {
+			return names, nil
+		}
+	}
+
+	in := emr.ListClustersInput{
+		ClusterStates: aws.StringSlice(ClusterStateActive),
+	}
+
+	var names []string
+	err := s.EMRAPI.ListClustersPages(&in, func(out *emr.ListClustersOutput, b bool) bool {
+		for _, c := range out.Clusters {
+			names = append(names, aws.StringValue(c.Name))
+		}
+		return true
+	})
+	if err != nil {
+		return nil, apiError("ListClusters", err)
+	}
+
+	if cache != "" {
+		writeNameCache(cache, names)
+	}
+
+	return names, nil
+}
+
+// CompleteInstanceGroupNames returns the instance group and fleet names defined in the configuration file
+// rendered for the cluster name.
+func (s *App) CompleteInstanceGroupNames(name string, filename string, profile string) ([]string, error) {
+	filename, err := s.ConfigFile(filename, profile)
+	if err != nil {
+		return nil, err
+	}
+
+	config, err := loadClusterConfig(filename, name, map[string]string{"name": name})
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	if config.Instances == nil {
+		return names, nil
+	}
+	for _, ig := range config.Instances.InstanceGroups {
+		names = append(names, aws.StringValue(ig.Name))
+	}
+	for _, f := range config.Instances.InstanceFleets {
+		names = append(names, aws.StringValue(f.Name))
+	}
+	return names, nil
+}
+
+func readNameCache(filename string) ([]string, bool) {
+	st, err := os.Stat(filename)
+	if err != nil || time.Since(st.ModTime()) > ClusterNameCacheTTL {
+		return nil, false
+	}
+
+	dat, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, false
+	}
+
+	var names []string
+	for _, n := range strings.Split(string(dat), "\n") {
+		if n != "" {
+			names = append(names, n)
+		}
+	}
+	return names, true
+}
+
+func writeNameCache(filename string, names []string) {
+	// The cache is best effort; failing to write it only makes the next completion slower.
+	if err := os.MkdirAll(path.Dir(filename), 0700); err != nil {
+		return
+	}
+	ioutil.WriteFile(filename, []byte(strings.Join(names, "\n")+"\n"), 0600)
+}
+
+/*
+ * Completion functions for BuildCLI
+ */
+func completeFlags(c *cli.Context) {
+	if c.Command.Flags == nil {
+		return
+	}
+	for _, f := range c.Command.Flags {
+		for _, n := range strings.Split(f.GetName(), ",") {
+			n = strings.TrimSpace(n)
+			if len(n) == 1 {
+				fmt.Fprintln(c.App.Writer, "-"+n)
+			} else if n != "" {
+				fmt.Fprintln(c.App.Writer, "--"+n)
+			}
+		}
+	}
+}
+
+func completeWords(c *cli.Context, words []string) {
+	for _, w := range words {
+		fmt.Fprintln(c.App.Writer, w)
+	}
+}
+
+// previousArg returns the word before the one being completed, as the shell helpers pass the words before it.
+func previousArg(args []string) string {
+	n := len(args)
+	if n < 2 || args[n-1] != "--"+cli.BashCompletionFlag.GetName() {
+		return ""
+	}
+	return args[n-2]
+}
+
+// completeClusterName completes cluster names for the first argument.
+func completeClusterName(a *App) cli.BashCompleteFunc {
+	return func(c *cli.Context) {
+		completeFlags(c)
+		if len(c.Args()) != 0 {
+			return
+		}
+		names, err := a.CompleteClusterNames()
+		if err != nil {
+			return
+		}
+		completeWords(c, names)
+	}
+}
+
+// completeClusterAndInstanceGroupName completes a cluster name and then an instance group name.
+func completeClusterAndInstanceGroupName(a *App) cli.BashCompleteFunc {
+	return func(c *cli.Context) {
+		switch len(c.Args()) {
+		case 0:
+			completeClusterName(a)(c)
+		case 1:
+			completeFlags(c)
+			names, err := a.CompleteInstanceGroupNames(c.Args().Get(0), c.String("filename"), c.String("profile"))
+			if err != nil {
+				return
+			}
+			completeWords(c, names)
+		default:
+			completeFlags(c)
+		}
+	}
+}
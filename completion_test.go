@@ -0,0 +1,152 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/*
+ * Test Init
+ */
+func TestInit(t *testing.T) {
+	// the shell helper returns the exit status of `emrcmd shell` instead of evaluating its empty output
+	status := map[string]string{"bash": "|| return $?", "zsh": "|| return $?", "fish": "return $code"}
+	for _, sh := range []string{"bash", "zsh", "fish"} {
+		a := NewMockApp()
+
+		err := a.Init(&AppInitOptions{Name: "emr", Shell: sh})
+		if err != nil {
+			t.Fatalf("Init command expected to success but failed with %s", err.Error())
+		}
+
+		out := a.Stdout.String()
+		if !strings.Contains(out, "_emr_complete") {
+			t.Errorf("completion function is expected in %s script but got '%s'", sh, out)
+		}
+		if !strings.Contains(out, "--generate-bash-completion") {
+			t.Errorf("completion command is expected in %s script but got '%s'", sh, out)
+		}
+		if !strings.Contains(out, status[sh]) {
+			t.Errorf("'%s' is expected in %s script but got '%s'", status[sh], sh, out)
+		}
+	}
+}
+
+func TestInitUnknownShell(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Init(&AppInitOptions{Shell: "csh"})
+	if err == nil {
+		t.Fatalf("Init command expected to fail but succeeded")
+	}
+}
+
+/*
+ * Test Completion
+ */
+func TestInitCompletion(t *testing.T) {
+	defer func(args []string) { os.Args = args }(os.Args)
+	cases := []struct {
+		args string
+		exp  bool
+	}{
+		{"emrcmd init --shell --generate-bash-completion", true},
+		{"emrcmd init -s --generate-bash-completion", true},
+		{"emrcmd init --generate-bash-completion", false},
+		{"emrcmd init emr --generate-bash-completion", false},
+	}
+	for _, c := range cases {
+		a := NewMockApp()
+		app := BuildCLI(&a.App)
+		app.Writer = a.Stdout
+		os.Args = strings.Fields(c.args)
+		app.Run(os.Args)
+
+		out := a.Stdout.String()
+		if got := strings.Contains(out, "zsh\n"); c.exp != got {
+			t.Errorf("%s: shells expected to be offered %v but got '%s'", c.args, c.exp, out)
+		}
+	}
+}
+
+func TestCompleteClusterNamesCache(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emrcmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	a := NewMockApp()
+	a.CacheDir = dir
+
+	names, err := a.CompleteClusterNames()
+	if err != nil {
+		t.Fatalf("CompleteClusterNames expected to success but failed with %s", err.Error())
+	}
+	if exp := []string{"test"}; !reflect.DeepEqual(exp, names) {
+		t.Errorf("%s expected but got %s", exp, names)
+	}
+
+	// second call must be served from the cache
+	a.EMRAPI.LastListClustersPagesInput = nil
+	a.EMRAPI.MockListClustersPages = func(*emr.ListClustersInput, func(*emr.ListClustersOutput, bool) bool) error {
+		t.Fatalf("ListClusters API is expected not to be called but called")
+		return nil
+	}
+	names, err = a.CompleteClusterNames()
+	if err != nil {
+		t.Fatalf("CompleteClusterNames expected to success but failed with %s", err.Error())
+	}
+	if exp := []string{"test"}; !reflect.DeepEqual(exp, names) {
+		t.Errorf("%s expected but got %s", exp, names)
+	}
+}
+
+func TestCompleteClusterNamesCachePerRegion(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emrcmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for _, c := range []struct {
+		profile, region, name string
+	}{
+		{"default", "us-east-1", "test"},
+		{"prod", "us-east-1", "prod-cluster"},
+		{"default", "ap-northeast-1", "tokyo-cluster"},
+	} {
+		a := NewMockApp()
+		a.CacheDir = dir
+		a.AWSProfile, a.AWSRegion = c.profile, c.region
+		a.EMRAPI.MockListClustersPages = func(input *emr.ListClustersInput, fn func(*emr.ListClustersOutput, bool) bool) error {
+			fn(&emr.ListClustersOutput{Clusters: []*emr.ClusterSummary{{Name: aws.String(c.name)}}}, true)
+			return nil
+		}
+
+		names, err := a.CompleteClusterNames()
+		if err != nil {
+			t.Fatalf("CompleteClusterNames expected to success but failed with %s", err.Error())
+		}
+		if exp := []string{c.name}; !reflect.DeepEqual(exp, names) {
+			t.Errorf("%s %s: %s expected but got %s", c.profile, c.region, exp, names)
+		}
+	}
+}
+
+func TestCompleteInstanceGroupNames(t *testing.T) {
+	a := NewMockApp()
+
+	names, err := a.CompleteInstanceGroupNames("test", "./cluster-sample.yml", "")
+	if err != nil {
+		t.Fatalf("CompleteInstanceGroupNames expected to success but failed with %s", err.Error())
+	}
+	if exp := []string{"master", "core", "task"}; !reflect.DeepEqual(exp, names) {
+		t.Errorf("%s expected but got %s", exp, names)
+	}
+}
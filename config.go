@@ -0,0 +1,534 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// mergeKeys are the fields used to match list elements when a config extends another.
+// e.g. instance groups are merged by name and configurations by classification.
+var mergeKeys = []string{"name", "classification", "key"}
+
+/*
+ * Cluster config loader
+ */
+type configLoader struct {
+	name string
+	vars map[string]string
+
+	// variables from --var-file and `vars` blocks of templates
+	fileVars map[string]*VariableLookup
+	defaults map[string]*VariableLookup
+
+	// files being rendered, to detect include/extends cycles
+	loading map[string]bool
+
+	// secrets referred by ssm and secret functions, and their values to be masked
+	secrets      SecretStore
+	secretValues []string
+
+	// spot prices referred by the spotprice function, cached per instance type
+	spotPrices     SpotPriceSource
+	spotPriceCache map[string]float64
+
+	// variables looked up while rendering, in order of first use
+	Lookups []*VariableLookup
+}
+
+// VariableLookup records where the value of a template variable came from.
+type VariableLookup struct {
+	Key    string
+	Value  interface{}
+	Source string
+}
+
+// Sources of template variables
+const (
+	VariableSourceArgument = "argument"
+	VariableSourceVarFile  = "var-file"
+	VariableSourceEnv      = "env"
+	VariableSourceTemplate = "vars in"
+	VariableSourceDefault  = "default"
+)
+
+func newConfigLoader(name string, vars map[string]string) *configLoader {
+	return &configLoader{
+		name:     name,
+		vars:     vars,
+		fileVars: map[string]*VariableLookup{},
+		defaults: map[string]*VariableLookup{},
+		loading:  map[string]bool{},
+	}
+}
+
+func loadClusterConfig(filename string, name string, vars map[string]string) (*emr.RunJobFlowInput, error) {
+	return newConfigLoader(name, vars).LoadConfig(filename)
+}
+
+// ClusterTemplate is a rendered template: RunJobFlowInput and the sections used only by emrcmd.
+type ClusterTemplate struct {
+	emr.RunJobFlowInput `yaml:",inline"`
+
+	Scaling     *ScalingConfig  `yaml:"scaling"`
+	Schedule    *ScheduleConfig `yaml:"schedule"`
+	IdleTimeout string          `yaml:"idle_timeout"`
+}
+
+// LoadConfig renders the template and decodes it into RunJobFlowInput.
+func (l *configLoader) LoadConfig(filename string) (*emr.RunJobFlowInput, error) {
+	t, err := l.LoadTemplate(filename)
+	if err != nil {
+		return nil, err
+	}
+	return &t.RunJobFlowInput, nil
+}
+
+// LoadTemplate renders the template and decodes it with the emrcmd sections.
+func (l *configLoader) LoadTemplate(filename string) (*ClusterTemplate, error) {
+	dat, merged, err := l.Load(filename)
+	if err != nil {
+		return nil, l.maskError(err)
+	}
+
+	t, err := decodeClusterTemplate(filename, dat)
+	if verr, ok := err.(*ValidationError); ok {
+		verr.Merged = merged
+	}
+	return t, l.maskError(err)
+}
+
+// WriteConfig writes the config with secret values masked. See writeConfig.
+func (l *configLoader) WriteConfig(w io.Writer, v interface{}, format string) error {
+	return writeConfig(w, v, format, l.Mask)
+}
+
+// Load renders the template and resolves `extends`.
+// The rendered text is returned as is unless the template extends another one,
+// in which case the merged config is marshalled again and merged is true.
+func (l *configLoader) Load(filename string) (dat []byte, merged bool, err error) {
+	err = l.loadDefaults(filename, map[string]bool{})
+	if err != nil {
+		return nil, false, err
+	}
+
+	dat, m, err := l.load(filename)
+	if err != nil {
+		return nil, false, err
+	}
+	if m == nil {
+		return dat, false, nil
+	}
+	dat, err = yaml.Marshal(m)
+	return dat, true, err
+}
+
+// load returns the rendered text, and the merged config if the template extends another one.
+func (l *configLoader) load(filename string) ([]byte, map[interface{}]interface{}, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	if l.loading[abs] {
+		return nil, nil, fmt.Errorf("%s is extended recursively", filename)
+	}
+	l.loading[abs] = true
+	defer delete(l.loading, abs)
+
+	dat, err := l.render(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m := map[interface{}]interface{}{}
+	err = yaml.Unmarshal(dat, &m)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %s", filename, err.Error())
+	}
+
+	ext, ok := m["extends"]
+	if !ok {
+		return dat, nil, nil
+	}
+	delete(m, "extends")
+
+	base, ok := ext.(string)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: extends must be a file name", filename)
+	}
+
+	baseDat, baseMap, err := l.load(relativePath(filename, base))
+	if err != nil {
+		return nil, nil, err
+	}
+	if baseMap == nil {
+		baseMap = map[interface{}]interface{}{}
+		err = yaml.Unmarshal(baseDat, &baseMap)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	merged := mergeConfig(baseMap, m).(map[interface{}]interface{})
+	return dat, merged, nil
+}
+
+// render executes the template file.
+func (l *configLoader) render(filename string) ([]byte, error) {
+	dat, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	defaults, text, err := extractVarsBlock(filename, string(dat))
+	if err != nil {
+		return nil, err
+	}
+	l.addDefaults(filename, defaults)
+
+	t, err := template.New(filepath.Base(filename)).Funcs(l.funcMap(filename)).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := bytes.NewBufferString("")
+	err = t.Execute(buf, l.data())
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (l *configLoader) funcMap(filename string) template.FuncMap {
+	funcMap := templateFuncs()
+	funcMap["name"] = func() string { return l.name }
+	funcMap["lookup"] = l.lookup
+	funcMap["required"] = l.required
+	funcMap["env"] = os.Getenv
+	funcMap["include"] = func(f string) (string, error) { return l.include(filename, f) }
+	funcMap["ssm"] = l.ssm
+	funcMap["secret"] = l.secret
+	funcMap["spotprice"] = l.spotprice
+	return funcMap
+}
+
+func (l *configLoader) lookup(key string, defval interface{}) interface{} {
+	if val, ok := l.resolve(key); ok {
+		return val
+	}
+
+	l.record(key, defval, VariableSourceDefault)
+	return defval
+}
+
+// required returns the variable, or fails if it is not given.
+func (l *configLoader) required(key string) (interface{}, error) {
+	if val, ok := l.resolve(key); ok {
+		return val, nil
+	}
+	return nil, fmt.Errorf("variable %s is required (pass %s=VALUE or set EMR_VAR_%s)", key, key, strings.ToUpper(key))
+}
+
+// resolve finds the variable and records where it came from.
+func (l *configLoader) resolve(key string) (interface{}, bool) {
+	v := l.find(key)
+	if v == nil {
+		return nil, false
+	}
+	l.record(v.Key, v.Value, v.Source)
+	return v.Value, true
+}
+
+// find looks up the variable from arguments, var files, environment variables and `vars` blocks in order.
+func (l *configLoader) find(key string) *VariableLookup {
+	if val, ok := l.vars[key]; ok {
+		return &VariableLookup{Key: key, Value: val, Source: VariableSourceArgument}
+	}
+
+	if v, ok := l.fileVars[key]; ok {
+		return v
+	}
+
+	env := "EMR_VAR_" + strings.ToUpper(key)
+	if val, ok := os.LookupEnv(env); ok {
+		return &VariableLookup{Key: key, Value: val, Source: VariableSourceEnv + " " + env}
+	}
+
+	if v, ok := l.defaults[key]; ok {
+		return v
+	}
+
+	return nil
+}
+
+func (l *configLoader) record(key string, val interface{}, source string) {
+	for _, v := range l.Lookups {
+		if v.Key == key {
+			return
+		}
+	}
+	l.Lookups = append(l.Lookups, &VariableLookup{Key: key, Value: val, Source: source})
+}
+
+// Explain writes the variables looked up while rendering and their sources.
+func (l *configLoader) Explain(w io.Writer) {
+	fmt.Fprintln(w, "Variables:")
+	if len(l.Lookups) == 0 {
+		fmt.Fprintln(w, "  (none)")
+	}
+	for _, v := range l.Lookups {
+		fmt.Fprintf(w, "  %s = %s (%s)\n", v.Key, l.Mask(fmt.Sprintf("%v", v.Value)), v.Source)
+	}
+}
+
+// include renders another template relative to the including file.
+func (l *configLoader) include(from string, filename string) (string, error) {
+	f := relativePath(from, filename)
+	abs, err := filepath.Abs(f)
+	if err != nil {
+		return "", err
+	}
+	if l.loading[abs] {
+		return "", fmt.Errorf("%s is included recursively", filename)
+	}
+	l.loading[abs] = true
+	defer delete(l.loading, abs)
+
+	dat, err := l.render(f)
+	if err != nil {
+		return "", err
+	}
+	return string(dat), nil
+}
+
+func relativePath(from string, filename string) string {
+	if filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join(filepath.Dir(from), filename)
+}
+
+/*
+ * Merge
+ */
+
+// mergeConfig deep-merges over into base.
+// Maps are merged recursively, and lists of maps are merged by one of mergeKeys.
+// Any other value in over replaces the one in base.
+func mergeConfig(base interface{}, over interface{}) interface{} {
+	switch o := over.(type) {
+	case map[interface{}]interface{}:
+		b, ok := base.(map[interface{}]interface{})
+		if !ok {
+			return o
+		}
+		ret := map[interface{}]interface{}{}
+		for k, v := range b {
+			ret[k] = v
+		}
+		for k, v := range o {
+			ret[k] = mergeConfig(b[k], v)
+		}
+		return ret
+
+	case []interface{}:
+		b, ok := base.([]interface{})
+		if !ok {
+			return o
+		}
+		key := findMergeKey(b, o)
+		if key == "" {
+			return o
+		}
+		return mergeList(key, b, o)
+
+	default:
+		return over
+	}
+}
+
+func mergeList(key string, base []interface{}, over []interface{}) []interface{} {
+	var ret []interface{}
+	index := map[interface{}]int{}
+	for _, v := range base {
+		index[v.(map[interface{}]interface{})[key]] = len(ret)
+		ret = append(ret, v)
+	}
+	for _, v := range over {
+		k := v.(map[interface{}]interface{})[key]
+		if i, ok := index[k]; ok {
+			ret[i] = mergeConfig(ret[i], v)
+		} else {
+			index[k] = len(ret)
+			ret = append(ret, v)
+		}
+	}
+	return ret
+}
+
+// findMergeKey returns the merge key which all the elements have.
+func findMergeKey(lists ...[]interface{}) string {
+	for _, key := range mergeKeys {
+		if hasMergeKey(key, lists...) {
+			return key
+		}
+	}
+	return ""
+}
+
+func hasMergeKey(key string, lists ...[]interface{}) bool {
+	n := 0
+	for _, l := range lists {
+		for _, v := range l {
+			m, ok := v.(map[interface{}]interface{})
+			if !ok {
+				return false
+			}
+			switch m[key].(type) {
+			case nil, map[interface{}]interface{}, []interface{}:
+				return false
+			}
+			n++
+		}
+	}
+	return n > 0
+}
+
+/*
+ * Output
+ */
+
+// Output formats of rendered configs
+const (
+	OutputFormatYAML = "yaml"
+	OutputFormatJSON = "json"
+)
+
+// writeConfig writes v in the lower-case YAML format used by templates,
+// or in JSON with the API member names, which is the RunJobFlow request shape.
+// Unset fields are omitted in both formats, and string values are passed through mask if given.
+func writeConfig(w io.Writer, v interface{}, format string, mask func(string) string) error {
+	switch format {
+	case "", OutputFormatYAML:
+		dat, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var m interface{}
+		err = yaml.Unmarshal(dat, &m)
+		if err != nil {
+			return err
+		}
+		dat, err = yaml.Marshal(maskConfig(pruneConfig(m), mask))
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(dat)
+		return err
+
+	case OutputFormatJSON:
+		dat, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		var m interface{}
+		err = json.Unmarshal(dat, &m)
+		if err != nil {
+			return err
+		}
+		dat, err = json.MarshalIndent(maskConfig(pruneConfig(m), mask), "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(w, string(dat))
+		return err
+
+	default:
+		return fmt.Errorf("unknown output format %s (yaml or json expected)", format)
+	}
+}
+
+// pruneConfig removes null values and empty lists and maps from decoded YAML or JSON.
+func pruneConfig(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		ret := map[interface{}]interface{}{}
+		for k, e := range t {
+			if e = pruneConfig(e); !isEmptyConfig(e) {
+				ret[k] = e
+			}
+		}
+		return ret
+	case map[string]interface{}:
+		ret := map[string]interface{}{}
+		for k, e := range t {
+			if e = pruneConfig(e); !isEmptyConfig(e) {
+				ret[k] = e
+			}
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(t))
+		for i, e := range t {
+			ret[i] = pruneConfig(e)
+		}
+		return ret
+	default:
+		return v
+	}
+}
+
+// maskConfig applies mask to all the string values of decoded YAML or JSON.
+func maskConfig(v interface{}, mask func(string) string) interface{} {
+	if mask == nil {
+		return v
+	}
+	switch t := v.(type) {
+	case string:
+		return mask(t)
+	case map[interface{}]interface{}:
+		ret := map[interface{}]interface{}{}
+		for k, e := range t {
+			ret[k] = maskConfig(e, mask)
+		}
+		return ret
+	case map[string]interface{}:
+		ret := map[string]interface{}{}
+		for k, e := range t {
+			ret[k] = maskConfig(e, mask)
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(t))
+		for i, e := range t {
+			ret[i] = maskConfig(e, mask)
+		}
+		return ret
+	default:
+		return v
+	}
+}
+
+func isEmptyConfig(v interface{}) bool {
+	switch t := v.(type) {
+	case nil:
+		return true
+	case map[interface{}]interface{}:
+		return len(t) == 0
+	case map[string]interface{}:
+		return len(t) == 0
+	case []interface{}:
+		return len(t) == 0
+	default:
+		return false
+	}
+}
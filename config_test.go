@@ -0,0 +1,93 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"reflect"
+	"testing"
+)
+
+/*
+ * Test loadClusterConfig
+ */
+func TestLoadClusterConfigInclude(t *testing.T) {
+	config, err := loadClusterConfig("./testdata/extends/base.yml", "test", map[string]string{})
+	if err != nil {
+		t.Fatalf("loadClusterConfig expected to success but failed with %s", err.Error())
+	}
+
+	if id := aws.StringValue(config.Instances.Ec2SubnetId); "subnet-00000000" != id {
+		t.Errorf("subnet-00000000 expected but got %s", id)
+	}
+}
+
+func TestLoadClusterConfigExtends(t *testing.T) {
+	config, err := loadClusterConfig("./testdata/extends/spark.yml", "test", map[string]string{"core": "3"})
+	if err != nil {
+		t.Fatalf("loadClusterConfig expected to success but failed with %s", err.Error())
+	}
+
+	if l := aws.StringValue(config.ReleaseLabel); "emr-5.10.0" != l {
+		t.Errorf("emr-5.10.0 expected but got %s", l)
+	}
+	if r := aws.StringValue(config.ServiceRole); "EMR_DefaultRole" != r {
+		t.Errorf("EMR_DefaultRole expected but got %s", r)
+	}
+	if id := aws.StringValue(config.Instances.Ec2SubnetId); "subnet-00000000" != id {
+		t.Errorf("subnet-00000000 expected but got %s", id)
+	}
+
+	expIgs := []*emr.InstanceGroupConfig{
+		{
+			Name:          aws.String("master"),
+			InstanceRole:  aws.String("MASTER"),
+			InstanceType:  aws.String("m3.xlarge"),
+			InstanceCount: aws.Int64(1),
+		},
+		{
+			Name:          aws.String("core"),
+			InstanceRole:  aws.String("CORE"),
+			InstanceType:  aws.String("r4.2xlarge"),
+			InstanceCount: aws.Int64(3),
+		},
+		{
+			Name:          aws.String("task"),
+			InstanceRole:  aws.String("TASK"),
+			InstanceType:  aws.String("r4.2xlarge"),
+			InstanceCount: aws.Int64(2),
+		},
+	}
+	if igs := config.Instances.InstanceGroups; !reflect.DeepEqual(expIgs, igs) {
+		t.Errorf("%s expected but got %s", expIgs, igs)
+	}
+
+	expConfs := []*emr.Configuration{
+		{
+			Classification: aws.String("hive-site"),
+			Properties: map[string]*string{
+				"hive.exec.parallel":        aws.String("false"),
+				"hive.exec.compress.output": aws.String("true"),
+			},
+		},
+		{
+			Classification: aws.String("spark"),
+			Properties: map[string]*string{
+				"maximizeResourceAllocation": aws.String("true"),
+			},
+		},
+	}
+	if confs := config.Configurations; !reflect.DeepEqual(expConfs, confs) {
+		t.Errorf("%s expected but got %s", expConfs, confs)
+	}
+
+	if n := len(config.Tags); 2 != n {
+		t.Errorf("2 tags expected but got %d", n)
+	}
+}
+
+func TestLoadClusterConfigExtendsLoop(t *testing.T) {
+	_, err := loadClusterConfig("./testdata/extends/loop.yml", "test", map[string]string{})
+	if err == nil {
+		t.Fatalf("loadClusterConfig expected to fail but succeeded")
+	}
+}
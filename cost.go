@@ -0,0 +1,512 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+	"gopkg.in/yaml.v2"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * Prices
+ *
+ * The price table is a YAML file of USD per instance hour:
+ *
+ *   m5.xlarge:
+ *     ec2: 0.192
+ *     emr: 0.048
+ *     spot: 0.08
+ *
+ * The spot price is optional. Spot instances are estimated at the on-demand EC2 price without it.
+ */
+
+// DefaultPriceFile is the price table used unless --prices is given.
+var DefaultPriceFile = path.Join(os.Getenv("HOME"), ".emrcmd", "prices.yml")
+
+// InstancePrice is the hourly price of an instance type.
+type InstancePrice struct {
+	EC2  float64 `yaml:"ec2"`
+	EMR  float64 `yaml:"emr"`
+	Spot float64 `yaml:"spot"`
+}
+
+// Hourly returns the EC2 and EMR price per hour in the market.
+func (p *InstancePrice) Hourly(market string) (float64, float64) {
+	if market == emr.MarketTypeSpot && p.Spot > 0 {
+		return p.Spot, p.EMR
+	}
+	return p.EC2, p.EMR
+}
+
+// PriceSource looks up the price of an instance type. nil is returned for unknown types.
+type PriceSource interface {
+	InstancePrice(instanceType string) (*InstancePrice, error)
+}
+
+// PriceTable is a price table loaded from a file.
+type PriceTable map[string]*InstancePrice
+
+func (t PriceTable) InstancePrice(instanceType string) (*InstancePrice, error) {
+	return t[instanceType], nil
+}
+
+func loadPriceTable(filename string) (PriceTable, error) {
+	dat, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	t := PriceTable{}
+	err = yaml.UnmarshalStrict(dat, &t)
+	if err != nil {
+		return nil, &ValidationError{Filename: filename, Errors: yamlConfigErrors(dat, err)}
+	}
+	return t, nil
+}
+
+// priceSources looks up the sources in order and returns the first price found.
+type priceSources []PriceSource
+
+func (ps priceSources) InstancePrice(instanceType string) (*InstancePrice, error) {
+	for _, s := range ps {
+		p, err := s.InstancePrice(instanceType)
+		if err != nil || p != nil {
+			return p, err
+		}
+	}
+	return nil, nil
+}
+
+// AWSPriceSource looks up the on-demand EC2 and EMR prices of the region with the AWS Price List API.
+type AWSPriceSource struct {
+	PricingAPI pricingiface.PricingAPI
+	Region     string
+
+	cache map[string]*InstancePrice
+}
+
+func (s *AWSPriceSource) InstancePrice(instanceType string) (*InstancePrice, error) {
+	if p, ok := s.cache[instanceType]; ok {
+		return p, nil
+	}
+
+	ec2, err := s.onDemandPrice("AmazonEC2", map[string]string{
+		"instanceType":    instanceType,
+		"regionCode":      s.Region,
+		"operatingSystem": "Linux",
+		"tenancy":         "Shared",
+		"preInstalledSw":  "NA",
+		"capacitystatus":  "Used",
+	})
+	if err != nil {
+		return nil, err
+	}
+	emrPrice, err := s.onDemandPrice("ElasticMapReduce", map[string]string{
+		"instanceType": instanceType,
+		"regionCode":   s.Region,
+		"softwareType": "EMR",
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var p *InstancePrice
+	if ec2 > 0 {
+		p = &InstancePrice{EC2: ec2, EMR: emrPrice}
+	}
+	if s.cache == nil {
+		s.cache = map[string]*InstancePrice{}
+	}
+	s.cache[instanceType] = p
+	return p, nil
+}
+
+// onDemandPrice returns the USD price per unit of the first on-demand term of the product, or 0 if not found.
+func (s *AWSPriceSource) onDemandPrice(service string, attrs map[string]string) (float64, error) {
+	in := pricing.GetProductsInput{
+		ServiceCode: aws.String(service),
+		MaxResults:  aws.Int64(1),
+	}
+	var keys []string
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		in.Filters = append(in.Filters, &pricing.Filter{
+			Type:  aws.String(pricing.FilterTypeTermMatch),
+			Field: aws.String(k),
+			Value: aws.String(attrs[k]),
+		})
+	}
+	out, err := s.PricingAPI.GetProducts(&in)
+	if err != nil {
+		return 0, apiError("GetProducts", err)
+	}
+
+	for _, product := range out.PriceList {
+		terms, _ := product["terms"].(map[string]interface{})
+		onDemand, _ := terms["OnDemand"].(map[string]interface{})
+		for _, term := range onDemand {
+			dims, _ := term.(map[string]interface{})["priceDimensions"].(map[string]interface{})
+			for _, dim := range dims {
+				unit, _ := dim.(map[string]interface{})["pricePerUnit"].(map[string]interface{})
+				if usd, ok := unit["USD"].(string); ok {
+					return strconv.ParseFloat(usd, 64)
+				}
+			}
+		}
+	}
+	return 0, nil
+}
+
+// newPriceSource returns the price table in the file followed by the Price List API if useAPI.
+// A missing file is ignored. nil is returned if there is no source.
+func (s *App) newPriceSource(filename string, useAPI bool) (PriceSource, error) {
+	var ret priceSources
+	if filename != "" {
+		t, err := loadPriceTable(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		if err == nil {
+			ret = append(ret, t)
+		}
+	}
+	if useAPI && s.Pricing != nil {
+		ret = append(ret, s.Pricing)
+	}
+	if len(ret) == 0 {
+		return nil, nil
+	}
+	return ret, nil
+}
+
+/*
+ * Cost estimation
+ */
+
+// CostEstimate is the estimated cost in USD.
+type CostEstimate struct {
+	Clusters int
+	Hours    float64 // instance hours
+	EC2      float64
+	EMR      float64
+
+	// instance types without price
+	Unknown map[string]bool
+}
+
+func (c *CostEstimate) Total() float64 {
+	return c.EC2 + c.EMR
+}
+
+func (c *CostEstimate) addUnknown(instanceType string) {
+	if c.Unknown == nil {
+		c.Unknown = map[string]bool{}
+	}
+	c.Unknown[instanceType] = true
+}
+
+// UnknownTypes returns the instance types without price in order.
+func (c *CostEstimate) UnknownTypes() []string {
+	var types []string
+	for t := range c.Unknown {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+func (c *CostEstimate) add(o *CostEstimate) {
+	c.Clusters += o.Clusters
+	c.Hours += o.Hours
+	c.EC2 += o.EC2
+	c.EMR += o.EMR
+	for t := range o.Unknown {
+		c.addUnknown(t)
+	}
+}
+
+// addInstances adds the cost of running n instances for hours.
+func (c *CostEstimate) addInstances(prices PriceSource, instanceType string, market string, n float64, hours float64) error {
+	c.Hours += n * hours
+	p, err := prices.InstancePrice(instanceType)
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		c.addUnknown(instanceType)
+		return nil
+	}
+	ec2, emrPrice := p.Hourly(market)
+	c.EC2 += ec2 * n * hours
+	c.EMR += emrPrice * n * hours
+	return nil
+}
+
+// configHourlyCost estimates the hourly cost of the instance groups or fleets in the config.
+// A fleet is estimated with its cheapest instance type per unit of capacity.
+func configHourlyCost(config *emr.RunJobFlowInput, prices PriceSource) (*CostEstimate, error) {
+	ret := &CostEstimate{Clusters: 1}
+	if config.Instances == nil {
+		return ret, nil
+	}
+
+	for _, ig := range config.Instances.InstanceGroups {
+		err := ret.addInstances(prices, aws.StringValue(ig.InstanceType), aws.StringValue(ig.Market), float64(aws.Int64Value(ig.InstanceCount)), 1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range config.Instances.InstanceFleets {
+		for _, m := range []struct {
+			market   string
+			capacity int64
+		}{
+			{emr.MarketTypeOnDemand, aws.Int64Value(f.TargetOnDemandCapacity)},
+			{emr.MarketTypeSpot, aws.Int64Value(f.TargetSpotCapacity)},
+		} {
+			if m.capacity == 0 || len(f.InstanceTypeConfigs) == 0 {
+				continue
+			}
+			// the cheapest type with a price, or the first type if none has
+			var best, first *CostEstimate
+			for _, tc := range f.InstanceTypeConfigs {
+				weight := float64(aws.Int64Value(tc.WeightedCapacity))
+				if weight == 0 {
+					weight = 1
+				}
+				c := &CostEstimate{}
+				err := c.addInstances(prices, aws.StringValue(tc.InstanceType), m.market, float64(m.capacity)/weight, 1)
+				if err != nil {
+					return nil, err
+				}
+				if first == nil {
+					first = c
+				}
+				if c.Unknown == nil && (best == nil || c.Total() < best.Total()) {
+					best = c
+				}
+			}
+			if best == nil {
+				best = first
+			}
+			ret.add(best)
+		}
+	}
+	return ret, nil
+}
+
+// clusterCost estimates the cost of the instances of the cluster running between from and to.
+func (s *App) clusterCost(id string, from time.Time, to time.Time, prices PriceSource) (*CostEstimate, error) {
+	ret := &CostEstimate{Clusters: 1}
+
+	var instances []*emr.Instance
+	in := emr.ListInstancesInput{ClusterId: aws.String(id)}
+	err := s.EMRAPI.ListInstancesPages(&in, func(out *emr.ListInstancesOutput, b bool) bool {
+		instances = append(instances, out.Instances...)
+		return true
+	})
+	if err != nil {
+		return nil, apiError("ListInstances", err)
+	}
+
+	for _, i := range instances {
+		if i.Status == nil || i.Status.Timeline == nil || i.Status.Timeline.CreationDateTime == nil {
+			continue
+		}
+		start, end := aws.TimeValue(i.Status.Timeline.CreationDateTime), to
+		if t := i.Status.Timeline.EndDateTime; t != nil && aws.TimeValue(t).Before(end) {
+			end = aws.TimeValue(t)
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if !end.After(start) {
+			continue
+		}
+		err := ret.addInstances(prices, aws.StringValue(i.InstanceType), aws.StringValue(i.Market), 1, end.Sub(start).Hours())
+		if err != nil {
+			return nil, err
+		}
+	}
+	return ret, nil
+}
+
+/*
+ * COST command
+ */
+type AppCostOptions struct {
+	Name       string // cluster name or id, or "" for all the clusters
+	Since      time.Duration
+	GroupBy    string // "name" or "tag:KEY"
+	PriceFile  string
+	PricingAPI bool
+}
+
+// Cost estimates the cost of clusters running in the last Since, grouped by name or a tag.
+func (s *App) Cost(o *AppCostOptions) error {
+	prices, err := s.newPriceSource(o.PriceFile, o.PricingAPI)
+	if err != nil {
+		return err
+	}
+	if prices == nil {
+		return fmt.Errorf("no prices: create %s or use --pricing-api", o.PriceFile)
+	}
+
+	var tagKey string
+	switch {
+	case o.GroupBy == "name":
+	case strings.HasPrefix(o.GroupBy, "tag:") && len(o.GroupBy) > 4:
+		tagKey = o.GroupBy[4:]
+	default:
+		return fmt.Errorf("invalid --group-by %s (name or tag:KEY expected)", o.GroupBy)
+	}
+
+	now := time.Now()
+	from := now.Add(-o.Since)
+	clusters, err := s.listClustersSince(from)
+	if err != nil {
+		return err
+	}
+
+	groups := map[string]*CostEstimate{}
+	total := &CostEstimate{}
+	for _, c := range clusters {
+		id, name := aws.StringValue(c.Id), aws.StringValue(c.Name)
+		if o.Name != "" && o.Name != name && o.Name != id {
+			continue
+		}
+
+		key := name
+		if tagKey != "" {
+			out, err := s.EMRAPI.DescribeCluster(&emr.DescribeClusterInput{ClusterId: c.Id})
+			if err != nil {
+				return apiError("DescribeCluster", err)
+			}
+			key = "(none)"
+			for _, t := range out.Cluster.Tags {
+				if aws.StringValue(t.Key) == tagKey {
+					key = aws.StringValue(t.Value)
+				}
+			}
+		}
+
+		cost, err := s.clusterCost(id, from, now, prices)
+		if err != nil {
+			return err
+		}
+		if groups[key] == nil {
+			groups[key] = &CostEstimate{}
+		}
+		groups[key].add(cost)
+		total.add(cost)
+	}
+
+	if o.Name != "" && total.Clusters == 0 {
+		return &NotFoundError{Kind: "cluster", Name: o.Name}
+	}
+
+	writeCosts(s.Stdout, groups, total)
+	if types := total.UnknownTypes(); len(types) > 0 {
+		fmt.Fprintf(s.Stderr, "no price for %s: their cost is not included\n", strings.Join(types, ", "))
+	}
+	return nil
+}
+
+// listClustersSince returns the active clusters and the clusters which ended after from.
+// Terminated clusters are listed regardless of when they were created, as one created long before from
+// may have run within the period.
+func (s *App) listClustersSince(from time.Time) ([]*emr.ClusterSummary, error) {
+	var ret []*emr.ClusterSummary
+	seen := map[string]bool{}
+	ended := []string{emr.ClusterStateTerminating, emr.ClusterStateTerminated, emr.ClusterStateTerminatedWithErrors}
+	for _, in := range []*emr.ListClustersInput{
+		{ClusterStates: aws.StringSlice(ClusterStateActive)},
+		{ClusterStates: aws.StringSlice(ended)},
+	} {
+		err := s.EMRAPI.ListClustersPages(in, func(out *emr.ListClustersOutput, b bool) bool {
+			for _, c := range out.Clusters {
+				if c.Status != nil && c.Status.Timeline != nil && c.Status.Timeline.EndDateTime != nil &&
+					aws.TimeValue(c.Status.Timeline.EndDateTime).Before(from) {
+					continue
+				}
+				if id := aws.StringValue(c.Id); !seen[id] {
+					seen[id] = true
+					ret = append(ret, c)
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, apiError("ListClusters", err)
+		}
+	}
+	return ret, nil
+}
+
+func writeCosts(w io.Writer, groups map[string]*CostEstimate, total *CostEstimate) {
+	var keys []string
+	width := len("TOTAL")
+	for k := range groups {
+		keys = append(keys, k)
+		if len(k) > width {
+			width = len(k)
+		}
+	}
+	sort.Strings(keys)
+
+	format := fmt.Sprintf("%%-%ds  %%8s  %%10s  %%10s  %%10s  %%10s\n", width)
+	fmt.Fprintf(w, format, "GROUP", "CLUSTERS", "HOURS", "EC2", "EMR", "TOTAL")
+	row := func(k string, c *CostEstimate) {
+		fmt.Fprintf(w, format, k, strconv.Itoa(c.Clusters), fmt.Sprintf("%.1f", c.Hours),
+			formatUSD(c.EC2), formatUSD(c.EMR), formatUSD(c.Total()))
+	}
+	for _, k := range keys {
+		row(k, groups[k])
+	}
+	row("TOTAL", total)
+}
+
+func formatUSD(v float64) string {
+	return fmt.Sprintf("$%.2f", v)
+}
+
+// printHourlyCost prints the estimated hourly cost of the config for `start --dryrun`.
+func (s *App) printHourlyCost(config *emr.RunJobFlowInput, prices PriceSource) error {
+	c, err := configHourlyCost(config, prices)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(s.Stderr, "Estimated cost: %s/hour (EC2 %s + EMR %s)\n", formatUSD(c.Total()), formatUSD(c.EC2), formatUSD(c.EMR))
+	if types := c.UnknownTypes(); len(types) > 0 {
+		fmt.Fprintf(s.Stderr, "  no price for %s: their cost is not included\n", strings.Join(types, ", "))
+	}
+	return nil
+}
+
+// parseSince parses a duration with the day unit as well, e.g. 7d or 12h.
+func parseSince(s string) (time.Duration, error) {
+	if strings.HasSuffix(s, "d") {
+		n, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+		if err == nil && n > 0 {
+			return time.Duration(n) * 24 * time.Hour, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid duration %s (e.g. 7d or 12h expected)", s)
+	}
+	return d, nil
+}
@@ -0,0 +1,264 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"github.com/aws/aws-sdk-go/service/pricing"
+	"github.com/aws/aws-sdk-go/service/pricing/pricingiface"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockCostClusters mocks clusters etl (x2, tagged team=data) and adhoc with their instances.
+func mockCostClusters(a *MockApp) {
+	now := time.Now()
+	instance := func(instanceType string, market string, created time.Duration, ended time.Duration) *emr.Instance {
+		tl := &emr.InstanceTimeline{CreationDateTime: aws.Time(now.Add(-created))}
+		if ended > 0 {
+			tl.EndDateTime = aws.Time(now.Add(-ended))
+		}
+		return &emr.Instance{
+			InstanceType: aws.String(instanceType),
+			Market:       aws.String(market),
+			Status:       &emr.InstanceStatus{Timeline: tl},
+		}
+	}
+	day := 24 * time.Hour
+	clusters := []*emr.Cluster{
+		{Id: aws.String("j-00000001"), Name: aws.String("etl"), Tags: []*emr.Tag{{Key: aws.String("team"), Value: aws.String("data")}}},
+		{Id: aws.String("j-00000002"), Name: aws.String("etl"), Tags: []*emr.Tag{{Key: aws.String("team"), Value: aws.String("data")}}},
+		{Id: aws.String("j-00000003"), Name: aws.String("adhoc")},
+	}
+	instances := map[string][]*emr.Instance{
+		"j-00000001": {
+			instance("m3.xlarge", emr.MarketTypeOnDemand, 10*time.Hour, 0),
+			instance("m3.xlarge", emr.MarketTypeOnDemand, 10*time.Hour, 0),
+			instance("m3.xlarge", emr.MarketTypeOnDemand, 9*day, 8*day),
+		},
+		"j-00000002": {
+			instance("m3.xlarge", emr.MarketTypeSpot, 2*time.Hour, time.Hour),
+		},
+		"j-00000003": {
+			instance("c5.large", emr.MarketTypeOnDemand, 30*day, 0),
+		},
+	}
+
+	mockClusters(a, clusters...)
+	a.EMRAPI.MockListInstancesPages = func(input *emr.ListInstancesInput, fn func(*emr.ListInstancesOutput, bool) bool) error {
+		fn(&emr.ListInstancesOutput{Instances: instances[aws.StringValue(input.ClusterId)]}, true)
+		return nil
+	}
+}
+
+/*
+ * Test Cost
+ */
+func TestCost(t *testing.T) {
+	a := NewMockApp()
+	mockCostClusters(a)
+
+	err := a.Cost(&AppCostOptions{
+		Since:     7 * 24 * time.Hour,
+		GroupBy:   "name",
+		PriceFile: "./testdata/cost/prices.yml",
+	})
+	if err != nil {
+		t.Fatalf("Cost command expected to success but failed with %s", err.Error())
+	}
+
+	exp := "GROUP  CLUSTERS       HOURS         EC2         EMR       TOTAL\n" +
+		"adhoc         1       168.0       $0.00       $0.00       $0.00\n" +
+		"etl           2        21.0       $5.42       $1.47       $6.89\n" +
+		"TOTAL         3       189.0       $5.42       $1.47       $6.89\n"
+	if out := a.Stdout.String(); exp != out {
+		t.Errorf("'%s' expected but got '%s'", exp, out)
+	}
+	if exp, msg := "no price for c5.large: their cost is not included\n", a.Stderr.String(); exp != msg {
+		t.Errorf("'%s' expected but got '%s'", exp, msg)
+	}
+}
+
+func TestCostByTag(t *testing.T) {
+	a := NewMockApp()
+	mockCostClusters(a)
+
+	err := a.Cost(&AppCostOptions{
+		Name:      "etl",
+		Since:     7 * 24 * time.Hour,
+		GroupBy:   "tag:team",
+		PriceFile: "./testdata/cost/prices.yml",
+	})
+	if err != nil {
+		t.Fatalf("Cost command expected to success but failed with %s", err.Error())
+	}
+
+	exp := "GROUP  CLUSTERS       HOURS         EC2         EMR       TOTAL\n" +
+		"data          2        21.0       $5.42       $1.47       $6.89\n" +
+		"TOTAL         2        21.0       $5.42       $1.47       $6.89\n"
+	if out := a.Stdout.String(); exp != out {
+		t.Errorf("'%s' expected but got '%s'", exp, out)
+	}
+}
+
+func TestCostNotFound(t *testing.T) {
+	a := NewMockApp()
+	mockCostClusters(a)
+
+	err := a.Cost(&AppCostOptions{
+		Name:      "foo",
+		Since:     7 * 24 * time.Hour,
+		GroupBy:   "name",
+		PriceFile: "./testdata/cost/prices.yml",
+	})
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Fatalf("NotFoundError expected but got %v", err)
+	}
+}
+
+func TestCostClustersEndedInPeriod(t *testing.T) {
+	a := NewMockApp()
+	now := time.Now()
+	day := 24 * time.Hour
+	ended := func(id string, created time.Duration, ended time.Duration) *emr.ClusterSummary {
+		return &emr.ClusterSummary{Id: aws.String(id), Name: aws.String("etl"), Status: &emr.ClusterStatus{
+			State:    aws.String(emr.ClusterStateTerminated),
+			Timeline: &emr.ClusterTimeline{CreationDateTime: aws.Time(now.Add(-created)), EndDateTime: aws.Time(now.Add(-ended))},
+		}}
+	}
+	a.EMRAPI.MockListClustersPages = func(input *emr.ListClustersInput, fn func(*emr.ListClustersOutput, bool) bool) error {
+		if input.CreatedAfter != nil || aws.StringValue(input.ClusterStates[0]) != emr.ClusterStateTerminating {
+			fn(&emr.ListClustersOutput{}, true)
+			return nil
+		}
+		// created before the period and ended within it, and ended before the period
+		fn(&emr.ListClustersOutput{Clusters: []*emr.ClusterSummary{ended("j-00000001", 30*day, 2*day), ended("j-00000002", 30*day, 10*day)}}, true)
+		return nil
+	}
+	a.EMRAPI.MockListInstancesPages = func(input *emr.ListInstancesInput, fn func(*emr.ListInstancesOutput, bool) bool) error {
+		fn(&emr.ListInstancesOutput{Instances: []*emr.Instance{{
+			InstanceType: aws.String("m3.xlarge"),
+			Market:       aws.String(emr.MarketTypeOnDemand),
+			Status: &emr.InstanceStatus{Timeline: &emr.InstanceTimeline{
+				CreationDateTime: aws.Time(now.Add(-30 * day)),
+				EndDateTime:      aws.Time(now.Add(-2 * day)),
+			}},
+		}}}, true)
+		return nil
+	}
+
+	err := a.Cost(&AppCostOptions{
+		Since:     7 * day,
+		GroupBy:   "name",
+		PriceFile: "./testdata/cost/prices.yml",
+	})
+	if err != nil {
+		t.Fatalf("Cost command expected to success but failed with %s", err.Error())
+	}
+
+	if exp, out := "etl           1       120.0", a.Stdout.String(); !strings.Contains(out, exp) {
+		t.Errorf("'%s' expected in '%s'", exp, out)
+	}
+}
+
+func TestStartDryRunCost(t *testing.T) {
+	cases := []struct {
+		filename string
+		vars     map[string]string
+		exp      string
+	}{
+		{"./cluster-sample.yml", map[string]string{"core": "2"},
+			"Estimated cost: $0.51/hour (EC2 $0.30 + EMR $0.21)\n"},
+		{"./testdata/fleet/cluster.yml", map[string]string{"task": "8"},
+			"Estimated cost: $0.72/hour (EC2 $0.58 + EMR $0.14)\n  no price for r5.xlarge: their cost is not included\n"},
+	}
+	for _, c := range cases {
+		a := NewMockApp()
+
+		err := a.Start(&AppStartOptions{
+			Name:            "test-cluster",
+			TemplateOptions: TemplateOptions{Filename: c.filename},
+			Vars:            c.vars,
+			DryRun:          true,
+			PriceFile:       "./testdata/cost/prices.yml",
+		})
+		if err != nil {
+			t.Fatalf("Start command expected to success but failed with %s", err.Error())
+		}
+
+		if msg := a.Stderr.String(); !strings.HasSuffix(msg, c.exp) {
+			t.Errorf("'%s' expected at the end of '%s'", c.exp, msg)
+		}
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	for s, exp := range map[string]time.Duration{"7d": 7 * 24 * time.Hour, "12h": 12 * time.Hour, "90m": 90 * time.Minute} {
+		if d, err := parseSince(s); err != nil || d != exp {
+			t.Errorf("%s expected for %s but got %s (%v)", exp, s, d, err)
+		}
+	}
+	for _, s := range []string{"", "0d", "-1h", "week"} {
+		if _, err := parseSince(s); err == nil {
+			t.Errorf("%s expected to be invalid", s)
+		}
+	}
+}
+
+/*
+ * Test AWSPriceSource
+ */
+type MockPricing struct {
+	pricingiface.PricingAPI
+	Inputs []*pricing.GetProductsInput
+}
+
+func (m *MockPricing) GetProducts(input *pricing.GetProductsInput) (*pricing.GetProductsOutput, error) {
+	m.Inputs = append(m.Inputs, input)
+	price := "0.1920000000"
+	if aws.StringValue(input.ServiceCode) == "ElasticMapReduce" {
+		price = "0.0480000000"
+	}
+	return &pricing.GetProductsOutput{
+		PriceList: []aws.JSONValue{{
+			"terms": map[string]interface{}{
+				"OnDemand": map[string]interface{}{
+					"SKU.TERM": map[string]interface{}{
+						"priceDimensions": map[string]interface{}{
+							"SKU.TERM.DIM": map[string]interface{}{
+								"pricePerUnit": map[string]interface{}{"USD": price},
+							},
+						},
+					},
+				},
+			},
+		}},
+	}, nil
+}
+
+func TestAWSPriceSource(t *testing.T) {
+	m := &MockPricing{}
+	s := &AWSPriceSource{PricingAPI: m, Region: "us-east-1"}
+
+	for i := 0; i < 2; i++ {
+		p, err := s.InstancePrice("m5.xlarge")
+		if err != nil {
+			t.Fatalf("InstancePrice expected to success but failed with %s", err.Error())
+		}
+		if exp := (InstancePrice{EC2: 0.192, EMR: 0.048}); exp != *p {
+			t.Errorf("%v expected but got %v", exp, *p)
+		}
+	}
+
+	if n := len(m.Inputs); 2 != n {
+		t.Fatalf("prices expected to be cached, but GetProducts called %d times", n)
+	}
+	var filters []string
+	for _, f := range m.Inputs[0].Filters {
+		filters = append(filters, aws.StringValue(f.Field)+"="+aws.StringValue(f.Value))
+	}
+	exp := "capacitystatus=Used,instanceType=m5.xlarge,operatingSystem=Linux,preInstalledSw=NA,regionCode=us-east-1,tenancy=Shared"
+	if f := strings.Join(filters, ","); exp != f {
+		t.Errorf("'%s' expected but got '%s'", exp, f)
+	}
+}
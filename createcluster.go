@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"strings"
+)
+
+/*
+ * JSON for `aws emr create-cluster --cli-input-json`
+ *
+ * create-cluster takes its own arguments instead of the RunJobFlow request:
+ * instance groups and EC2 attributes at the top level, tags as KEY=VALUE,
+ * and boolean pairs such as AutoTerminate and NoAutoTerminate.
+ */
+
+// createClusterInput converts the RunJobFlow request to the arguments of `aws emr create-cluster`.
+// Nil values are left in the map, which are pruned when the config is written.
+func createClusterInput(in *emr.RunJobFlowInput) (map[string]interface{}, error) {
+	if len(in.NewSupportedProducts) > 0 || len(in.SupportedProducts) > 0 {
+		return nil, fmt.Errorf("supported products cannot be given to aws emr create-cluster, use applications")
+	}
+
+	ret := map[string]interface{}{
+		"Name":                    in.Name,
+		"ReleaseLabel":            in.ReleaseLabel,
+		"OsReleaseLabel":          in.OSReleaseLabel,
+		"AmiVersion":              in.AmiVersion,
+		"LogUri":                  in.LogUri,
+		"LogEncryptionKmsKeyId":   in.LogEncryptionKmsKeyId,
+		"AdditionalInfo":          in.AdditionalInfo,
+		"ServiceRole":             in.ServiceRole,
+		"AutoScalingRole":         in.AutoScalingRole,
+		"AutoTerminationPolicy":   in.AutoTerminationPolicy,
+		"Configurations":          in.Configurations,
+		"CustomAmiId":             in.CustomAmiId,
+		"EbsRootVolumeSize":       in.EbsRootVolumeSize,
+		"EbsRootVolumeIops":       in.EbsRootVolumeIops,
+		"EbsRootVolumeThroughput": in.EbsRootVolumeThroughput,
+		"KerberosAttributes":      in.KerberosAttributes,
+		"ManagedScalingPolicy":    in.ManagedScalingPolicy,
+		"PlacementGroupConfigs":   in.PlacementGroupConfigs,
+		"RepoUpgradeOnBoot":       in.RepoUpgradeOnBoot,
+		"ScaleDownBehavior":       in.ScaleDownBehavior,
+		"SecurityConfiguration":   in.SecurityConfiguration,
+		"StepConcurrencyLevel":    in.StepConcurrencyLevel,
+	}
+	setFlagPair(ret, "VisibleToAllUsers", in.VisibleToAllUsers)
+
+	var apps []map[string]interface{}
+	for _, a := range in.Applications {
+		apps = append(apps, map[string]interface{}{"Name": a.Name, "Args": a.Args})
+	}
+	ret["Applications"] = apps
+
+	var actions []map[string]interface{}
+	for _, b := range in.BootstrapActions {
+		if b.ScriptBootstrapAction == nil {
+			continue
+		}
+		actions = append(actions, map[string]interface{}{
+			"Name": b.Name,
+			"Path": b.ScriptBootstrapAction.Path,
+			"Args": b.ScriptBootstrapAction.Args,
+		})
+	}
+	ret["BootstrapActions"] = actions
+
+	var steps []map[string]interface{}
+	for _, s := range in.Steps {
+		step := map[string]interface{}{"Type": "CUSTOM_JAR", "Name": s.Name, "ActionOnFailure": s.ActionOnFailure}
+		if j := s.HadoopJarStep; j != nil {
+			var props []string
+			for _, p := range j.Properties {
+				props = append(props, aws.StringValue(p.Key)+"="+aws.StringValue(p.Value))
+			}
+			step["Jar"], step["Args"], step["MainClass"] = j.Jar, j.Args, j.MainClass
+			if len(props) > 0 {
+				step["Properties"] = strings.Join(props, ",")
+			}
+		}
+		steps = append(steps, step)
+	}
+	ret["Steps"] = steps
+
+	var tags []string
+	for _, t := range in.Tags {
+		tags = append(tags, aws.StringValue(t.Key)+"="+aws.StringValue(t.Value))
+	}
+	ret["Tags"] = tags
+
+	ec2 := map[string]interface{}{"InstanceProfile": in.JobFlowRole}
+	ret["Ec2Attributes"] = ec2
+
+	i := in.Instances
+	if i == nil {
+		return ret, nil
+	}
+	if i.HadoopVersion != nil {
+		return nil, fmt.Errorf("hadoopversion cannot be given to aws emr create-cluster")
+	}
+	if i.SlaveInstanceType != nil && aws.StringValue(i.SlaveInstanceType) != aws.StringValue(i.MasterInstanceType) {
+		return nil, fmt.Errorf("slaveinstancetype different from masterinstancetype cannot be given to aws emr create-cluster, use instancegroups")
+	}
+
+	ec2["KeyName"] = i.Ec2KeyName
+	ec2["SubnetId"] = i.Ec2SubnetId
+	ec2["SubnetIds"] = i.Ec2SubnetIds
+	ec2["EmrManagedMasterSecurityGroup"] = i.EmrManagedMasterSecurityGroup
+	ec2["EmrManagedSlaveSecurityGroup"] = i.EmrManagedSlaveSecurityGroup
+	ec2["ServiceAccessSecurityGroup"] = i.ServiceAccessSecurityGroup
+	ec2["AdditionalMasterSecurityGroups"] = i.AdditionalMasterSecurityGroups
+	ec2["AdditionalSlaveSecurityGroups"] = i.AdditionalSlaveSecurityGroups
+	if p := i.Placement; p != nil {
+		ec2["AvailabilityZone"] = p.AvailabilityZone
+		ec2["AvailabilityZones"] = p.AvailabilityZones
+	}
+
+	ret["InstanceType"] = i.MasterInstanceType
+	ret["InstanceCount"] = i.InstanceCount
+	ret["InstanceFleets"] = i.InstanceFleets
+
+	var groups []map[string]interface{}
+	for _, ig := range i.InstanceGroups {
+		g := map[string]interface{}{
+			"Name":              ig.Name,
+			"InstanceGroupType": ig.InstanceRole,
+			"InstanceType":      ig.InstanceType,
+			"InstanceCount":     ig.InstanceCount,
+			"EbsConfiguration":  ig.EbsConfiguration,
+			"AutoScalingPolicy": ig.AutoScalingPolicy,
+			"Configurations":    ig.Configurations,
+			"CustomAmiId":       ig.CustomAmiId,
+		}
+		// create-cluster makes a group SPOT by its bid price
+		if aws.StringValue(ig.Market) == emr.MarketTypeSpot {
+			g["BidPrice"] = ig.BidPrice
+			if ig.BidPrice == nil {
+				g["BidPrice"] = "OnDemandPrice"
+			}
+		}
+		groups = append(groups, g)
+	}
+	ret["InstanceGroups"] = groups
+
+	// the cluster is kept alive unless the steps end it
+	if i.KeepJobFlowAliveWhenNoSteps != nil {
+		setFlagPair(ret, "AutoTerminate", aws.Bool(!aws.BoolValue(i.KeepJobFlowAliveWhenNoSteps)))
+	}
+	setFlagPair(ret, "TerminationProtected", i.TerminationProtected)
+	setFlagPair(ret, "UnhealthyNodeReplacement", i.UnhealthyNodeReplacement)
+
+	return ret, nil
+}
+
+// setFlagPair sets either of the boolean arguments `--name` and `--no-name` of create-cluster if v is set.
+func setFlagPair(m map[string]interface{}, name string, v *bool) {
+	if v == nil {
+		return
+	}
+	if *v {
+		m[name] = true
+	} else {
+		m["No"+name] = true
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/*
+ * Test createClusterInput
+ */
+func TestCreateClusterInput(t *testing.T) {
+	in := &emr.RunJobFlowInput{
+		Name:              aws.String("test"),
+		JobFlowRole:       aws.String("EMR_EC2_DefaultRole"),
+		VisibleToAllUsers: aws.Bool(false),
+		Applications:      []*emr.Application{{Name: aws.String("Spark"), Version: aws.String("2.2.0")}},
+		BootstrapActions: []*emr.BootstrapActionConfig{{
+			Name:                  aws.String("setup"),
+			ScriptBootstrapAction: &emr.ScriptBootstrapActionConfig{Path: aws.String("s3://bucket/setup.sh")},
+		}},
+		Steps: []*emr.StepConfig{{
+			Name:            aws.String("etl"),
+			ActionOnFailure: aws.String(emr.ActionOnFailureContinue),
+			HadoopJarStep: &emr.HadoopJarStepConfig{
+				Jar:        aws.String("command-runner.jar"),
+				Args:       aws.StringSlice([]string{"spark-submit", "etl.py"}),
+				Properties: []*emr.KeyValue{{Key: aws.String("a"), Value: aws.String("1")}, {Key: aws.String("b"), Value: aws.String("2")}},
+			},
+		}},
+		Instances: &emr.JobFlowInstancesConfig{
+			Ec2KeyName:                  aws.String("key"),
+			Placement:                   &emr.PlacementType{AvailabilityZone: aws.String("us-east-1a")},
+			KeepJobFlowAliveWhenNoSteps: aws.Bool(false),
+			TerminationProtected:        aws.Bool(true),
+			InstanceGroups: []*emr.InstanceGroupConfig{
+				{Name: aws.String("master"), InstanceRole: aws.String("MASTER"), Market: aws.String(emr.MarketTypeOnDemand), BidPrice: aws.String("0.5")},
+				{Name: aws.String("task"), InstanceRole: aws.String("TASK"), Market: aws.String(emr.MarketTypeSpot)},
+			},
+		},
+	}
+
+	out, err := createClusterInput(in)
+	if err != nil {
+		t.Fatalf("createClusterInput expected to success but failed with %s", err.Error())
+	}
+
+	ec2 := out["Ec2Attributes"].(map[string]interface{})
+	for k, exp := range map[string]string{"InstanceProfile": "EMR_EC2_DefaultRole", "KeyName": "key", "AvailabilityZone": "us-east-1a"} {
+		if got := aws.StringValue(ec2[k].(*string)); exp != got {
+			t.Errorf("Ec2Attributes %s: %s expected but got %s", k, exp, got)
+		}
+	}
+	for _, k := range []string{"AutoTerminate", "TerminationProtected", "NoVisibleToAllUsers"} {
+		if out[k] != true {
+			t.Errorf("%s expected but got %v", k, out)
+		}
+	}
+
+	groups := out["InstanceGroups"].([]map[string]interface{})
+	if _, ok := groups[0]["BidPrice"]; ok {
+		t.Errorf("no bid price expected for an on-demand group but got %v", groups[0])
+	}
+	if p := groups[1]["BidPrice"]; "OnDemandPrice" != p {
+		t.Errorf("OnDemandPrice expected for a spot group without bid price but got %v", p)
+	}
+
+	apps := out["Applications"].([]map[string]interface{})
+	if _, ok := apps[0]["Version"]; ok {
+		t.Errorf("applications are expected to be given by name but got %v", apps[0])
+	}
+	if p := out["BootstrapActions"].([]map[string]interface{})[0]["Path"]; "s3://bucket/setup.sh" != aws.StringValue(p.(*string)) {
+		t.Errorf("bootstrap action path expected but got %v", p)
+	}
+	step := out["Steps"].([]map[string]interface{})[0]
+	exp := map[string]interface{}{"Type": "CUSTOM_JAR", "Properties": "a=1,b=2"}
+	for k, v := range exp {
+		if !reflect.DeepEqual(v, step[k]) {
+			t.Errorf("step %s: %v expected but got %v", k, v, step[k])
+		}
+	}
+}
+
+func TestCreateClusterInputUnsupported(t *testing.T) {
+	for _, c := range []struct {
+		in  *emr.RunJobFlowInput
+		msg string
+	}{
+		{&emr.RunJobFlowInput{SupportedProducts: aws.StringSlice([]string{"mapr-m3"})}, "supported products"},
+		{&emr.RunJobFlowInput{Instances: &emr.JobFlowInstancesConfig{HadoopVersion: aws.String("2.7.3")}}, "hadoopversion"},
+		{&emr.RunJobFlowInput{Instances: &emr.JobFlowInstancesConfig{
+			MasterInstanceType: aws.String("m5.xlarge"),
+			SlaveInstanceType:  aws.String("r5.xlarge"),
+		}}, "slaveinstancetype"},
+	} {
+		_, err := createClusterInput(c.in)
+		if err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Errorf("error about %s expected but got %v", c.msg, err)
+		}
+	}
+}
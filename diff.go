@@ -0,0 +1,304 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"gopkg.in/yaml.v2"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+/*
+ * Diff cluster against template
+ */
+
+// ConfigDiff is a difference between a running cluster and the rendered template.
+// Cluster is nil if the item is only in the template, and Template is nil if it is only on the cluster.
+type ConfigDiff struct {
+	Path     string
+	Cluster  interface{}
+	Template interface{}
+}
+
+func (d *ConfigDiff) String() string {
+	switch {
+	case d.Cluster == nil:
+		return fmt.Sprintf("+ %s: %s", d.Path, formatDiffValue(d.Template))
+	case d.Template == nil:
+		return fmt.Sprintf("- %s: %s", d.Path, formatDiffValue(d.Cluster))
+	default:
+		return fmt.Sprintf("~ %s: %s => %s", d.Path, formatDiffValue(d.Cluster), formatDiffValue(d.Template))
+	}
+}
+
+type AppDiffOptions struct {
+	Name string
+	Vars map[string]string
+	TemplateOptions
+}
+
+// Diff prints the differences between the running cluster and the rendered template.
+// Lines prefixed by `-` are only on the cluster, `+` only in the template, and `~` differ in value.
+// It returns DriftError if there is any difference.
+func (s *App) Diff(o *AppDiffOptions) error {
+	filename, err := s.ConfigFile(o.Filename, o.Profile)
+	if err != nil {
+		return err
+	}
+
+	loader, err := s.newConfigLoader(o.Name, o.Vars, o.VarFiles)
+	if err != nil {
+		return err
+	}
+
+	config, err := loader.LoadConfig(filename)
+	if err != nil {
+		return err
+	}
+
+	id, err := s.FindClusterId(o.Name)
+	if err != nil {
+		return err
+	}
+
+	current, err := s.ExportClusterConfig(id)
+	if err != nil {
+		return err
+	}
+
+	diffs := diffClusterConfig(current, config)
+	if len(diffs) == 0 {
+		fmt.Fprintf(s.Stderr, "%s (%s) is up to date with %s\n", o.Name, id, filename)
+		return nil
+	}
+
+	fmt.Fprintf(s.Stdout, "--- %s (%s)\n+++ %s\n", o.Name, id, filename)
+	writeDiffs(s.Stdout, diffs, loader.Mask)
+	return &DriftError{Name: o.Name, Count: len(diffs)}
+}
+
+// diffClusterConfig compares the items of the cluster config which are kept while the cluster is running.
+func diffClusterConfig(current *emr.RunJobFlowInput, config *emr.RunJobFlowInput) []*ConfigDiff {
+	var diffs []*ConfigDiff
+
+	diffs = append(diffs, diffValues("releaselabel",
+		aws.StringValue(current.ReleaseLabel), aws.StringValue(config.ReleaseLabel))...)
+	diffs = append(diffs, diffItems("applications",
+		applicationItems(current.Applications), applicationItems(config.Applications))...)
+	diffs = append(diffs, diffItems("instancegroups",
+		instanceGroupItems(current.Instances), instanceGroupItems(config.Instances))...)
+	diffs = append(diffs, diffItems("instancefleets",
+		instanceFleetItems(current.Instances), instanceFleetItems(config.Instances))...)
+	diffs = append(diffs, diffItems("configurations",
+		configurationItems(current.Configurations), configurationItems(config.Configurations))...)
+	diffs = append(diffs, diffItems("tags",
+		tagItems(current.Tags), tagItems(config.Tags))...)
+	diffs = append(diffs, diffItems("bootstrapactions",
+		bootstrapActionItems(current.BootstrapActions), bootstrapActionItems(config.BootstrapActions))...)
+
+	return diffs
+}
+
+// diffItems compares items keyed by their names. Items with the same name are compared field by field.
+func diffItems(path string, current map[string]interface{}, config map[string]interface{}) []*ConfigDiff {
+	var diffs []*ConfigDiff
+	for _, k := range sortedKeys(current, config) {
+		p := path + "." + k
+		c, cok := current[k]
+		t, tok := config[k]
+		switch {
+		case !tok:
+			diffs = append(diffs, &ConfigDiff{Path: p, Cluster: c})
+		case !cok:
+			diffs = append(diffs, &ConfigDiff{Path: p, Template: t})
+		default:
+			cm, cIsMap := c.(map[string]interface{})
+			tm, tIsMap := t.(map[string]interface{})
+			if cIsMap && tIsMap {
+				diffs = append(diffs, diffFields(p, cm, tm)...)
+			} else {
+				diffs = append(diffs, diffValues(p, c, t)...)
+			}
+		}
+	}
+	return diffs
+}
+
+// diffFields compares the fields of an item. A field missing on one side is compared with nil.
+func diffFields(path string, current map[string]interface{}, config map[string]interface{}) []*ConfigDiff {
+	var diffs []*ConfigDiff
+	for _, k := range sortedKeys(current, config) {
+		c, t := current[k], config[k]
+		if !reflect.DeepEqual(c, t) {
+			diffs = append(diffs, &ConfigDiff{Path: path + "." + k, Cluster: c, Template: t})
+		}
+	}
+	return diffs
+}
+
+func diffValues(path string, current interface{}, config interface{}) []*ConfigDiff {
+	if reflect.DeepEqual(current, config) {
+		return nil
+	}
+	return []*ConfigDiff{{Path: path, Cluster: current, Template: config}}
+}
+
+// applicationItems compares applications by lower-case name as the API reports them capitalized.
+func applicationItems(apps []*emr.Application) map[string]interface{} {
+	ret := map[string]interface{}{}
+	for _, app := range apps {
+		name := strings.ToLower(aws.StringValue(app.Name))
+		ret[name] = name
+	}
+	return ret
+}
+
+// instanceGroupItems compares the type and the count of the groups.
+// Groups of size 0 are the same as missing ones, which resize adds on demand.
+func instanceGroupItems(instances *emr.JobFlowInstancesConfig) map[string]interface{} {
+	ret := map[string]interface{}{}
+	if instances == nil {
+		return ret
+	}
+	for _, ig := range instances.InstanceGroups {
+		if aws.Int64Value(ig.InstanceCount) == 0 {
+			continue
+		}
+		ret[aws.StringValue(ig.Name)] = map[string]interface{}{
+			"instancerole":  aws.StringValue(ig.InstanceRole),
+			"instancetype":  aws.StringValue(ig.InstanceType),
+			"instancecount": aws.Int64Value(ig.InstanceCount),
+		}
+	}
+	return ret
+}
+
+// instanceFleetItems compares the type, the target capacities and the instance types of the fleets.
+// Fleets without capacity are the same as missing ones as start omits them.
+func instanceFleetItems(instances *emr.JobFlowInstancesConfig) map[string]interface{} {
+	ret := map[string]interface{}{}
+	if instances == nil {
+		return ret
+	}
+	for _, f := range instances.InstanceFleets {
+		onDemand, spot := aws.Int64Value(f.TargetOnDemandCapacity), aws.Int64Value(f.TargetSpotCapacity)
+		if onDemand+spot == 0 {
+			continue
+		}
+		var types []string
+		for _, c := range f.InstanceTypeConfigs {
+			types = append(types, aws.StringValue(c.InstanceType))
+		}
+		sort.Strings(types)
+		instanceTypes := make([]interface{}, len(types))
+		for i, t := range types {
+			instanceTypes[i] = t
+		}
+		ret[aws.StringValue(f.Name)] = map[string]interface{}{
+			"instancefleettype":      aws.StringValue(f.InstanceFleetType),
+			"targetondemandcapacity": onDemand,
+			"targetspotcapacity":     spot,
+			"instancetypes":          instanceTypes,
+		}
+	}
+	return ret
+}
+
+func configurationItems(configs []*emr.Configuration) map[string]interface{} {
+	ret := map[string]interface{}{}
+	for _, c := range configs {
+		ret[aws.StringValue(c.Classification)] = genericConfig(c)
+	}
+	return ret
+}
+
+func tagItems(tags []*emr.Tag) map[string]interface{} {
+	ret := map[string]interface{}{}
+	for _, t := range tags {
+		ret[aws.StringValue(t.Key)] = aws.StringValue(t.Value)
+	}
+	return ret
+}
+
+func bootstrapActionItems(actions []*emr.BootstrapActionConfig) map[string]interface{} {
+	ret := map[string]interface{}{}
+	for _, a := range actions {
+		ret[aws.StringValue(a.Name)] = genericConfig(a.ScriptBootstrapAction)
+	}
+	return ret
+}
+
+// genericConfig converts v to maps with the lower-case keys used by templates, omitting unset fields.
+func genericConfig(v interface{}) interface{} {
+	dat, err := yaml.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	var m interface{}
+	if err := yaml.Unmarshal(dat, &m); err != nil {
+		return fmt.Sprint(v)
+	}
+	return stringKeys(pruneConfig(m))
+}
+
+// stringKeys converts decoded YAML maps to map[string]interface{} to compare and print them as JSON.
+func stringKeys(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		ret := map[string]interface{}{}
+		for k, e := range t {
+			ret[fmt.Sprint(k)] = stringKeys(e)
+		}
+		return ret
+	case []interface{}:
+		ret := make([]interface{}, len(t))
+		for i, e := range t {
+			ret[i] = stringKeys(e)
+		}
+		return ret
+	default:
+		return v
+	}
+}
+
+func sortedKeys(maps ...map[string]interface{}) []string {
+	seen := map[string]bool{}
+	var ret []string
+	for _, m := range maps {
+		for k := range m {
+			if !seen[k] {
+				seen[k] = true
+				ret = append(ret, k)
+			}
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// formatDiffValue prints scalars as they are and structured values as compact JSON.
+func formatDiffValue(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "(none)"
+	case map[string]interface{}, []interface{}:
+		dat, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(dat)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// writeDiffs prints diffs one per line.
+func writeDiffs(w io.Writer, diffs []*ConfigDiff, mask func(string) string) {
+	for _, d := range diffs {
+		fmt.Fprintln(w, mask(d.String()))
+	}
+}
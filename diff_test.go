@@ -0,0 +1,85 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+ * Test Diff
+ */
+func TestDiff(t *testing.T) {
+	a := NewMockApp()
+	mockExportCluster(a)
+
+	err := a.Diff(&AppDiffOptions{
+		Name:            "test",
+		Vars:            map[string]string{"name": "test", "core": "2"},
+		TemplateOptions: TemplateOptions{Filename: "./cluster-sample.yml"},
+	})
+	if code := exitCode(err); ExitCodeDrift != code {
+		t.Fatalf("exit code %d expected but got %d (%v)", ExitCodeDrift, code, err)
+	}
+
+	exp := strings.Join([]string{
+		"--- test (j-00000000)",
+		"+++ ./cluster-sample.yml",
+		"+ applications.tez: tez",
+		"~ instancegroups.core.instancecount: 3 => 2",
+		`~ configurations.hive-site.properties: {"hive.exec.parallel":"true"} => {"hive.exec.compress.output":"true","hive.exec.parallel":"true"}`,
+		`- bootstrapactions.setup: {"args":["-v"],"path":"s3://bucket/setup.sh"}`,
+		"",
+	}, "\n")
+	if out := a.Stdout.String(); exp != out {
+		t.Errorf("'%s' expected but got '%s'", exp, out)
+	}
+}
+
+func TestDiffUpToDate(t *testing.T) {
+	a := NewMockApp()
+	mockExportCluster(a)
+	a.EMRAPI.MockListBootstrapActionsPages = nil
+
+	err := a.Diff(&AppDiffOptions{
+		Name:            "test",
+		Vars:            map[string]string{"name": "test", "core": "3"},
+		TemplateOptions: TemplateOptions{Filename: "./testdata/diff/cluster.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Diff command expected to success but failed with %s", err.Error())
+	}
+
+	if out := a.Stdout.String(); out != "" {
+		t.Errorf("no differences expected but got '%s'", out)
+	}
+	if msg := a.Stderr.String(); !strings.Contains(msg, "is up to date") {
+		t.Errorf("up to date message expected but got '%s'", msg)
+	}
+}
+
+func TestDiffFleets(t *testing.T) {
+	a := NewMockApp()
+	mockInstanceFleets(a)
+
+	err := a.Diff(&AppDiffOptions{
+		Name:            "test",
+		Vars:            map[string]string{"name": "test", "core_spot": "8", "task": "4"},
+		TemplateOptions: TemplateOptions{Filename: "./testdata/fleet/cluster.yml"},
+	})
+	if code := exitCode(err); ExitCodeDrift != code {
+		t.Fatalf("exit code %d expected but got %d (%v)", ExitCodeDrift, code, err)
+	}
+
+	out := a.Stdout.String()
+	for _, exp := range []string{
+		"~ instancefleets.core.targetspotcapacity: 4 => 8",
+		`+ instancefleets.task: {"instancefleettype":"TASK","instancetypes":["r5.xlarge"],"targetondemandcapacity":0,"targetspotcapacity":4}`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("'%s' expected in '%s'", exp, out)
+		}
+	}
+	if strings.Contains(out, "instancefleets.master") {
+		t.Errorf("no differences of master expected but got '%s'", out)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/urfave/cli.v1"
+	"strings"
+	"time"
+)
+
+// Exit codes returned by emrcmd.
+const (
+	ExitCodeError       = 1 // unclassified error, including invalid arguments
+	ExitCodeNotFound    = 3 // cluster or instance group is not found
+	ExitCodeAmbiguous   = 4 // name matches more than one cluster
+	ExitCodeAPIError    = 5 // AWS API call failed
+	ExitCodeUnreachable = 6 // cluster master could not be reached
+	ExitCodeInvalid     = 7 // cluster config is invalid
+	ExitCodeTimeout     = 8 // gave up waiting for the cluster
+	ExitCodeDrift       = 9 // diff found the cluster differs from its config
+)
+
+// NotFoundError is returned when a named resource does not exist.
+type NotFoundError struct {
+	Kind string
+	Name string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %s is not found", e.Kind, e.Name)
+}
+
+// AmbiguousError is returned when a name matches more than one resource.
+type AmbiguousError struct {
+	Kind       string
+	Name       string
+	Candidates []string
+}
+
+func (e *AmbiguousError) Error() string {
+	return fmt.Sprintf("%s %s is ambiguous: %s", e.Kind, e.Name, strings.Join(e.Candidates, ", "))
+}
+
+// APIError wraps an error returned from the AWS API.
+type APIError struct {
+	Op  string
+	Err error
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("%s failed: %s", e.Op, e.Err.Error())
+}
+
+// UnreachableError is returned when a remote endpoint on the cluster cannot be reached.
+type UnreachableError struct {
+	URL string
+	Err error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("%s is unreachable: %s", e.URL, e.Err.Error())
+}
+
+// TimeoutError is returned when the cluster does not reach the expected state in time.
+type TimeoutError struct {
+	Target  string
+	Timeout time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s waiting for %s", e.Timeout, e.Target)
+}
+
+// DriftError is returned when a running cluster differs from its cluster config.
+type DriftError struct {
+	Name  string
+	Count int
+}
+
+func (e *DriftError) Error() string {
+	return fmt.Sprintf("%s has %d differences from its cluster config", e.Name, e.Count)
+}
+
+// apiError wraps err in APIError unless it is nil.
+func apiError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &APIError{Op: op, Err: err}
+}
+
+func exitCode(err error) int {
+	switch err.(type) {
+	case *NotFoundError:
+		return ExitCodeNotFound
+	case *AmbiguousError:
+		return ExitCodeAmbiguous
+	case *APIError:
+		return ExitCodeAPIError
+	case *UnreachableError:
+		return ExitCodeUnreachable
+	case *ValidationError:
+		return ExitCodeInvalid
+	case *TimeoutError:
+		return ExitCodeTimeout
+	case *DriftError:
+		return ExitCodeDrift
+	default:
+		return ExitCodeError
+	}
+}
+
+// exitError converts err into cli.ExitError with the exit code of its kind.
+func exitError(err error) error {
+	return cli.NewExitError(err, exitCode(err))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+)
+
+/*
+ * Export cluster config
+ */
+type AppExportOptions struct {
+	Name   string
+	Output string
+}
+
+// Export writes the configuration of a running cluster in the template format read by start.
+func (s *App) Export(o *AppExportOptions) error {
+	id, err := s.FindClusterId(o.Name)
+	if err != nil {
+		return err
+	}
+
+	config, err := s.ExportClusterConfig(id)
+	if err != nil {
+		return err
+	}
+
+	// keep the exported file usable as a template for another cluster name
+	name := aws.StringValue(config.Name)
+	config.Name = aws.String("{{name}}")
+
+	if o.Output == "" || o.Output == OutputFormatYAML {
+		fmt.Fprintf(s.Stdout, "# exported from %s (%s)\n---\n", name, id)
+	}
+	return writeConfig(s.Stdout, config, o.Output, nil)
+}
+
+// ExportClusterConfig rebuilds RunJobFlowInput of the cluster.
+func (s *App) ExportClusterConfig(id string) (*emr.RunJobFlowInput, error) {
+	out, err := s.EMRAPI.DescribeCluster(&emr.DescribeClusterInput{ClusterId: aws.String(id)})
+	if err != nil {
+		return nil, apiError("DescribeCluster", err)
+	}
+	c := out.Cluster
+
+	config := &emr.RunJobFlowInput{
+		Name:                  c.Name,
+		ReleaseLabel:          c.ReleaseLabel,
+		ServiceRole:           c.ServiceRole,
+		AutoScalingRole:       c.AutoScalingRole,
+		SecurityConfiguration: c.SecurityConfiguration,
+		LogUri:                c.LogUri,
+		CustomAmiId:           c.CustomAmiId,
+		EbsRootVolumeSize:     c.EbsRootVolumeSize,
+		ScaleDownBehavior:     c.ScaleDownBehavior,
+		StepConcurrencyLevel:  c.StepConcurrencyLevel,
+		VisibleToAllUsers:     c.VisibleToAllUsers,
+		Configurations:        c.Configurations,
+		Tags:                  c.Tags,
+		Instances: &emr.JobFlowInstancesConfig{
+			KeepJobFlowAliveWhenNoSteps: aws.Bool(!aws.BoolValue(c.AutoTerminate)),
+			TerminationProtected:        c.TerminationProtected,
+		},
+	}
+
+	for _, app := range c.Applications {
+		config.Applications = append(config.Applications, &emr.Application{Name: app.Name})
+	}
+
+	if attr := c.Ec2InstanceAttributes; attr != nil {
+		config.JobFlowRole = attr.IamInstanceProfile
+		config.Instances.Ec2KeyName = attr.Ec2KeyName
+		config.Instances.EmrManagedMasterSecurityGroup = attr.EmrManagedMasterSecurityGroup
+		config.Instances.EmrManagedSlaveSecurityGroup = attr.EmrManagedSlaveSecurityGroup
+		config.Instances.ServiceAccessSecurityGroup = attr.ServiceAccessSecurityGroup
+		config.Instances.AdditionalMasterSecurityGroups = attr.AdditionalMasterSecurityGroups
+		config.Instances.AdditionalSlaveSecurityGroups = attr.AdditionalSlaveSecurityGroups
+		if len(attr.RequestedEc2SubnetIds) > 1 {
+			config.Instances.Ec2SubnetIds = attr.RequestedEc2SubnetIds
+		} else {
+			config.Instances.Ec2SubnetId = attr.Ec2SubnetId
+		}
+	}
+
+	if aws.StringValue(c.InstanceCollectionType) == emr.InstanceCollectionTypeInstanceFleet {
+		config.Instances.InstanceFleets, err = s.exportInstanceFleets(id)
+	} else {
+		config.Instances.InstanceGroups, err = s.exportInstanceGroups(id)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	config.BootstrapActions, err = s.exportBootstrapActions(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
+
+func (s *App) exportInstanceGroups(id string) ([]*emr.InstanceGroupConfig, error) {
+	in := emr.ListInstanceGroupsInput{ClusterId: aws.String(id)}
+	var igs []*emr.InstanceGroup
+	err := s.EMRAPI.ListInstanceGroupsPages(&in, func(out *emr.ListInstanceGroupsOutput, b bool) bool {
+		igs = append(igs, out.InstanceGroups...)
+		return true
+	})
+	if err != nil {
+		return nil, apiError("ListInstanceGroups", err)
+	}
+	sortInstanceGroups(igs)
+
+	var ret []*emr.InstanceGroupConfig
+	for _, ig := range igs {
+		ret = append(ret, &emr.InstanceGroupConfig{
+			Name:             ig.Name,
+			InstanceRole:     ig.InstanceGroupType,
+			InstanceType:     ig.InstanceType,
+			InstanceCount:    ig.RequestedInstanceCount,
+			Market:           ig.Market,
+			BidPrice:         ig.BidPrice,
+			CustomAmiId:      ig.CustomAmiId,
+			Configurations:   ig.Configurations,
+			EbsConfiguration: exportEbsConfiguration(ig.EbsBlockDevices, ig.EbsOptimized),
+		})
+	}
+	return ret, nil
+}
+
+func (s *App) exportInstanceFleets(id string) ([]*emr.InstanceFleetConfig, error) {
+	in := emr.ListInstanceFleetsInput{ClusterId: aws.String(id)}
+	var ret []*emr.InstanceFleetConfig
+	err := s.EMRAPI.ListInstanceFleetsPages(&in, func(out *emr.ListInstanceFleetsOutput, b bool) bool {
+		for _, f := range out.InstanceFleets {
+			fc := &emr.InstanceFleetConfig{
+				Name:                   f.Name,
+				InstanceFleetType:      f.InstanceFleetType,
+				TargetOnDemandCapacity: f.TargetOnDemandCapacity,
+				TargetSpotCapacity:     f.TargetSpotCapacity,
+				LaunchSpecifications:   f.LaunchSpecifications,
+				ResizeSpecifications:   f.ResizeSpecifications,
+			}
+			for _, spec := range f.InstanceTypeSpecifications {
+				fc.InstanceTypeConfigs = append(fc.InstanceTypeConfigs, &emr.InstanceTypeConfig{
+					InstanceType:                        spec.InstanceType,
+					WeightedCapacity:                    spec.WeightedCapacity,
+					BidPrice:                            spec.BidPrice,
+					BidPriceAsPercentageOfOnDemandPrice: spec.BidPriceAsPercentageOfOnDemandPrice,
+					CustomAmiId:                         spec.CustomAmiId,
+					Priority:                            spec.Priority,
+					Configurations:                      spec.Configurations,
+					EbsConfiguration:                    exportEbsConfiguration(spec.EbsBlockDevices, spec.EbsOptimized),
+				})
+			}
+			ret = append(ret, fc)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, apiError("ListInstanceFleets", err)
+	}
+	return ret, nil
+}
+
+func (s *App) exportBootstrapActions(id string) ([]*emr.BootstrapActionConfig, error) {
+	in := emr.ListBootstrapActionsInput{ClusterId: aws.String(id)}
+	var ret []*emr.BootstrapActionConfig
+	err := s.EMRAPI.ListBootstrapActionsPages(&in, func(out *emr.ListBootstrapActionsOutput, b bool) bool {
+		for _, cmd := range out.BootstrapActions {
+			ret = append(ret, &emr.BootstrapActionConfig{
+				Name: cmd.Name,
+				ScriptBootstrapAction: &emr.ScriptBootstrapActionConfig{
+					Path: cmd.ScriptPath,
+					Args: cmd.Args,
+				},
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, apiError("ListBootstrapActions", err)
+	}
+	return ret, nil
+}
+
+// exportEbsConfiguration groups the attached EBS volumes by their specification.
+func exportEbsConfiguration(devices []*emr.EbsBlockDevice, optimized *bool) *emr.EbsConfiguration {
+	if len(devices) == 0 && optimized == nil {
+		return nil
+	}
+
+	ret := &emr.EbsConfiguration{EbsOptimized: optimized}
+	index := map[string]*emr.EbsBlockDeviceConfig{}
+	for _, d := range devices {
+		if d.VolumeSpecification == nil {
+			continue
+		}
+		key := d.VolumeSpecification.String()
+		if c, ok := index[key]; ok {
+			c.VolumesPerInstance = aws.Int64(aws.Int64Value(c.VolumesPerInstance) + 1)
+			continue
+		}
+		c := &emr.EbsBlockDeviceConfig{
+			VolumeSpecification: d.VolumeSpecification,
+			VolumesPerInstance:  aws.Int64(1),
+		}
+		index[key] = c
+		ret.EbsBlockDeviceConfigs = append(ret.EbsBlockDeviceConfigs, c)
+	}
+	return ret
+}
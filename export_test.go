@@ -0,0 +1,159 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func mockExportCluster(a *MockApp) {
+	a.EMRAPI.MockDescribeCluster = func(input *emr.DescribeClusterInput) (*emr.DescribeClusterOutput, error) {
+		return &emr.DescribeClusterOutput{
+			Cluster: &emr.Cluster{
+				Id:                aws.String("j-00000000"),
+				Name:              aws.String("test"),
+				ReleaseLabel:      aws.String("emr-5.9.0"),
+				ServiceRole:       aws.String("EMR_DefaultRole"),
+				AutoTerminate:     aws.Bool(false),
+				VisibleToAllUsers: aws.Bool(true),
+				Applications: []*emr.Application{
+					{Name: aws.String("Hadoop"), Version: aws.String("2.7.3")},
+					{Name: aws.String("Hive"), Version: aws.String("2.3.0")},
+				},
+				Configurations: []*emr.Configuration{
+					{
+						Classification: aws.String("hive-site"),
+						Properties:     map[string]*string{"hive.exec.parallel": aws.String("true")},
+					},
+				},
+				Ec2InstanceAttributes: &emr.Ec2InstanceAttributes{
+					Ec2SubnetId:        aws.String("subnet-00000000"),
+					IamInstanceProfile: aws.String("EMR_EC2_DefaultRole"),
+				},
+				Tags: []*emr.Tag{{Key: aws.String("Name"), Value: aws.String("EMR-test")}},
+			},
+		}, nil
+	}
+	a.EMRAPI.MockListInstanceGroupsPages = func(input *emr.ListInstanceGroupsInput, fn func(*emr.ListInstanceGroupsOutput, bool) bool) error {
+		fn(&emr.ListInstanceGroupsOutput{
+			InstanceGroups: []*emr.InstanceGroup{
+				{
+					Name:                   aws.String("core"),
+					InstanceGroupType:      aws.String(emr.InstanceGroupTypeCore),
+					InstanceType:           aws.String("m3.xlarge"),
+					Market:                 aws.String(emr.MarketTypeSpot),
+					BidPrice:               aws.String("0.5"),
+					RequestedInstanceCount: aws.Int64(3),
+					EbsBlockDevices: []*emr.EbsBlockDevice{
+						{Device: aws.String("/dev/sdb"), VolumeSpecification: &emr.VolumeSpecification{SizeInGB: aws.Int64(100), VolumeType: aws.String("gp2")}},
+						{Device: aws.String("/dev/sdc"), VolumeSpecification: &emr.VolumeSpecification{SizeInGB: aws.Int64(100), VolumeType: aws.String("gp2")}},
+					},
+				},
+				{
+					Name:                   aws.String("master"),
+					InstanceGroupType:      aws.String(emr.InstanceGroupTypeMaster),
+					InstanceType:           aws.String("m3.xlarge"),
+					Market:                 aws.String(emr.MarketTypeOnDemand),
+					RequestedInstanceCount: aws.Int64(1),
+				},
+			},
+		}, true)
+		return nil
+	}
+	a.EMRAPI.MockListBootstrapActionsPages = func(input *emr.ListBootstrapActionsInput, fn func(*emr.ListBootstrapActionsOutput, bool) bool) error {
+		fn(&emr.ListBootstrapActionsOutput{
+			BootstrapActions: []*emr.Command{
+				{Name: aws.String("setup"), ScriptPath: aws.String("s3://bucket/setup.sh"), Args: aws.StringSlice([]string{"-v"})},
+			},
+		}, true)
+		return nil
+	}
+}
+
+/*
+ * Test Export
+ */
+func TestExport(t *testing.T) {
+	a := NewMockApp()
+	mockExportCluster(a)
+
+	err := a.Export(&AppExportOptions{Name: "test"})
+	if err != nil {
+		t.Fatalf("Export command expected to success but failed with %s", err.Error())
+	}
+
+	out := a.Stdout.String()
+	if !strings.HasPrefix(out, "# exported from test (j-00000000)\n---\n") {
+		t.Errorf("header comment expected but got '%s'", out)
+	}
+
+	// the exported template can be loaded again
+	f, err := ioutil.TempFile("", "emrcmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(out)
+	f.Close()
+
+	config, err := loadClusterConfig(f.Name(), "copy", map[string]string{})
+	if err != nil {
+		t.Fatalf("exported template expected to be loaded but failed with %s", err.Error())
+	}
+
+	if name := aws.StringValue(config.Name); "copy" != name {
+		t.Errorf("copy expected but got %s", name)
+	}
+	if role := aws.StringValue(config.JobFlowRole); "EMR_EC2_DefaultRole" != role {
+		t.Errorf("EMR_EC2_DefaultRole expected but got %s", role)
+	}
+	if !aws.BoolValue(config.Instances.KeepJobFlowAliveWhenNoSteps) {
+		t.Errorf("keepjobflowalivewhennosteps expected to be true")
+	}
+
+	igs := config.Instances.InstanceGroups
+	if n := len(igs); 2 != n {
+		t.Fatalf("2 instance groups expected but got %d", n)
+	}
+	if name := aws.StringValue(igs[0].Name); "master" != name {
+		t.Errorf("master expected first but got %s", name)
+	}
+	expEbs := &emr.EbsConfiguration{
+		EbsBlockDeviceConfigs: []*emr.EbsBlockDeviceConfig{
+			{
+				VolumeSpecification: &emr.VolumeSpecification{SizeInGB: aws.Int64(100), VolumeType: aws.String("gp2")},
+				VolumesPerInstance:  aws.Int64(2),
+			},
+		},
+	}
+	if ebs := igs[1].EbsConfiguration; !reflect.DeepEqual(expEbs, ebs) {
+		t.Errorf("%s expected but got %s", expEbs, ebs)
+	}
+
+	expApps := []*emr.Application{{Name: aws.String("Hadoop")}, {Name: aws.String("Hive")}}
+	if !reflect.DeepEqual(expApps, config.Applications) {
+		t.Errorf("%s expected but got %s", expApps, config.Applications)
+	}
+
+	if path := aws.StringValue(config.BootstrapActions[0].ScriptBootstrapAction.Path); "s3://bucket/setup.sh" != path {
+		t.Errorf("s3://bucket/setup.sh expected but got %s", path)
+	}
+}
+
+func TestExportById(t *testing.T) {
+	a := NewMockApp()
+	mockExportCluster(a)
+
+	err := a.Export(&AppExportOptions{Name: "j-00000000"})
+	if err != nil {
+		t.Fatalf("Export command expected to success but failed with %s", err.Error())
+	}
+
+	if a.EMRAPI.LastListClustersPagesInput != nil {
+		t.Errorf("ListClusters API is expected not to be called with cluster id")
+	}
+}
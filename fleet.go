@@ -0,0 +1,198 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"sort"
+	"time"
+)
+
+/*
+ * Instance fleets
+ */
+
+func (s *App) FindInstanceFleetByName(id string, name string) (*emr.InstanceFleet, error) {
+	fleets, err := s.listInstanceFleets(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range fleets {
+		if aws.StringValue(f.Name) == name {
+			return f, nil
+		}
+	}
+	return nil, nil
+}
+
+func (s *App) listInstanceFleets(id string) ([]*emr.InstanceFleet, error) {
+	in := emr.ListInstanceFleetsInput{ClusterId: aws.String(id)}
+
+	var ret []*emr.InstanceFleet
+	err := s.EMRAPI.ListInstanceFleetsPages(&in, func(out *emr.ListInstanceFleetsOutput, b bool) bool {
+		ret = append(ret, out.InstanceFleets...)
+		return true
+	})
+	if err != nil {
+		return nil, apiError("ListInstanceFleets", err)
+	}
+	return ret, nil
+}
+
+// sortInstanceFleets sorts instance fleets in MASTER, CORE and TASK order.
+func sortInstanceFleets(fleets []*emr.InstanceFleet) {
+	sort.SliceStable(fleets, func(i, j int) bool {
+		return encodeInstanceGroupType(fleets[i].InstanceFleetType) < encodeInstanceGroupType(fleets[j].InstanceFleetType)
+	})
+}
+
+// resizeInstanceFleet changes the target capacities of the fleet, or adds the fleet if it does not exist.
+// Capacities not given in the options are kept, or taken from the template for a new fleet.
+func (s *App) resizeInstanceFleet(o *AppResizeOptions, loader *configLoader, config *emr.InstanceFleetConfig) error {
+	name := aws.StringValue(config.Name)
+	if o.OnDemand == nil && o.Spot == nil {
+		return fmt.Errorf("%s is an instance fleet: use --on-demand and --spot to resize it", name)
+	}
+	if o.Graceful {
+		return fmt.Errorf("--graceful is not supported for instance fleet %s", name)
+	}
+
+	c, err := s.FindByName(o.Name)
+	if err != nil {
+		return err
+	}
+
+	f, err := s.FindInstanceFleetByName(aws.StringValue(c.Id), name)
+	if err != nil {
+		return err
+	}
+
+	if f != nil {
+		config.TargetOnDemandCapacity = f.TargetOnDemandCapacity
+		config.TargetSpotCapacity = f.TargetSpotCapacity
+	}
+	if o.OnDemand != nil {
+		config.TargetOnDemandCapacity = o.OnDemand
+	}
+	if o.Spot != nil {
+		config.TargetSpotCapacity = o.Spot
+	}
+
+	if o.Explain {
+		loader.Explain(s.Stderr)
+	}
+
+	if o.DryRun {
+		fmt.Fprintln(s.Stderr, "Resize cluster with:")
+		return loader.WriteConfig(s.Stdout, config, o.Output)
+	}
+
+	var fleetId *string
+	if f == nil {
+		in := emr.AddInstanceFleetInput{
+			ClusterId:     c.Id,
+			InstanceFleet: config,
+		}
+		out, err := s.EMRAPI.AddInstanceFleet(&in)
+		if err != nil {
+			return apiError("AddInstanceFleet", err)
+		}
+		fleetId = out.InstanceFleetId
+	} else {
+		in := emr.ModifyInstanceFleetInput{
+			ClusterId: c.Id,
+			InstanceFleet: &emr.InstanceFleetModifyConfig{
+				InstanceFleetId:        f.Id,
+				TargetOnDemandCapacity: config.TargetOnDemandCapacity,
+				TargetSpotCapacity:     config.TargetSpotCapacity,
+			},
+		}
+		_, err := s.EMRAPI.ModifyInstanceFleet(&in)
+		if err != nil {
+			return apiError("ModifyInstanceFleet", err)
+		}
+		fleetId = f.Id
+	}
+
+	fmt.Fprintf(s.Stderr, "resizing %s to %d on-demand and %d spot units...\n", name,
+		aws.Int64Value(config.TargetOnDemandCapacity), aws.Int64Value(config.TargetSpotCapacity))
+
+	if o.Wait && fleetId != nil {
+		return s.WaitInstanceFleet(aws.StringValue(c.Id), aws.StringValue(fleetId),
+			aws.Int64Value(config.TargetOnDemandCapacity), aws.Int64Value(config.TargetSpotCapacity), o.Timeout)
+	}
+	return nil
+}
+
+// WaitInstanceFleet polls the instance fleet until the given target capacities are provisioned.
+// The targets of the fleet are not trusted, as they may not be updated yet right after a resize.
+func (s *App) WaitInstanceFleet(clusterId string, fleetId string, onDemand int64, spot int64, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var progress, reason string
+	for {
+		fleets, err := s.listInstanceFleets(clusterId)
+		if err != nil {
+			return err
+		}
+		var f *emr.InstanceFleet
+		for _, e := range fleets {
+			if aws.StringValue(e.Id) == fleetId {
+				f = e
+			}
+		}
+		if f == nil {
+			return &NotFoundError{Kind: "instance fleet", Name: fleetId}
+		}
+
+		name := aws.StringValue(f.Name)
+		state := ""
+		if f.Status != nil {
+			state = aws.StringValue(f.Status.State)
+			if f.Status.StateChangeReason != nil {
+				if r := aws.StringValue(f.Status.StateChangeReason.Message); r != "" && r != reason {
+					fmt.Fprintf(s.Stderr, "%s: %s\n", name, r)
+					reason = r
+				}
+			}
+		}
+
+		if p := fmt.Sprintf("%s: %s (%s)", name, formatFleetCapacity(f), state); p != progress {
+			fmt.Fprintln(s.Stderr, p)
+			progress = p
+		}
+
+		switch state {
+		case emr.InstanceFleetStateRunning:
+			if fleetProvisioned(f, onDemand, spot) {
+				fmt.Fprintf(s.Stderr, "%s is resized\n", name)
+				return nil
+			}
+		case emr.InstanceFleetStateSuspended, emr.InstanceFleetStateTerminated:
+			return fmt.Errorf("instance fleet %s is %s: %s", name, state, reason)
+		}
+
+		if timeout > 0 && time.Now().After(deadline) {
+			return &TimeoutError{Target: fmt.Sprintf("instance fleet %s (%s)", name, formatFleetCapacity(f)), Timeout: timeout}
+		}
+		time.Sleep(ResizePollInterval)
+	}
+}
+
+func fleetProvisioned(f *emr.InstanceFleet, onDemand int64, spot int64) bool {
+	return aws.Int64Value(f.TargetOnDemandCapacity) == onDemand && aws.Int64Value(f.ProvisionedOnDemandCapacity) == onDemand &&
+		aws.Int64Value(f.TargetSpotCapacity) == spot && aws.Int64Value(f.ProvisionedSpotCapacity) == spot
+}
+
+// formatFleetCapacity prints provisioned capacities with the targets in parentheses if they differ.
+func formatFleetCapacity(f *emr.InstanceFleet) string {
+	return fmt.Sprintf("%s on-demand, %s spot",
+		formatCapacity(aws.Int64Value(f.ProvisionedOnDemandCapacity), aws.Int64Value(f.TargetOnDemandCapacity)),
+		formatCapacity(aws.Int64Value(f.ProvisionedSpotCapacity), aws.Int64Value(f.TargetSpotCapacity)))
+}
+
+func formatCapacity(run int64, req int64) string {
+	if run == req {
+		return fmt.Sprintf("%d", run)
+	}
+	return fmt.Sprintf("%d(%d)", run, req)
+}
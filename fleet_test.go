@@ -0,0 +1,202 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mockInstanceFleets(a *MockApp) {
+	a.EMRAPI.MockDescribeCluster = func(input *emr.DescribeClusterInput) (*emr.DescribeClusterOutput, error) {
+		return &emr.DescribeClusterOutput{
+			Cluster: &emr.Cluster{
+				MasterPublicDnsName:    aws.String("master-public-dns-name"),
+				InstanceCollectionType: aws.String(emr.InstanceCollectionTypeInstanceFleet),
+			},
+		}, nil
+	}
+	a.EMRAPI.MockListInstanceFleetsPages = func(input *emr.ListInstanceFleetsInput, fn func(*emr.ListInstanceFleetsOutput, bool) bool) error {
+		fn(&emr.ListInstanceFleetsOutput{
+			InstanceFleets: []*emr.InstanceFleet{
+				{
+					Id:                          aws.String("if-00000002"),
+					Name:                        aws.String("core"),
+					InstanceFleetType:           aws.String(emr.InstanceFleetTypeCore),
+					TargetOnDemandCapacity:      aws.Int64(2),
+					ProvisionedOnDemandCapacity: aws.Int64(2),
+					TargetSpotCapacity:          aws.Int64(4),
+					ProvisionedSpotCapacity:     aws.Int64(1),
+					InstanceTypeSpecifications: []*emr.InstanceTypeSpecification{
+						{InstanceType: aws.String("m5.xlarge")},
+						{InstanceType: aws.String("m4.xlarge")},
+					},
+				},
+				{
+					Id:                          aws.String("if-00000001"),
+					Name:                        aws.String("master"),
+					InstanceFleetType:           aws.String(emr.InstanceFleetTypeMaster),
+					TargetOnDemandCapacity:      aws.Int64(1),
+					ProvisionedOnDemandCapacity: aws.Int64(1),
+					InstanceTypeSpecifications: []*emr.InstanceTypeSpecification{
+						{InstanceType: aws.String("m5.xlarge")},
+					},
+				},
+			},
+		}, true)
+		return nil
+	}
+}
+
+/*
+ * Test instance fleets
+ */
+func TestStartFleets(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test-cluster",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/fleet/cluster.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Start command expected to success but failed with %s", err.Error())
+	}
+
+	fleets := a.EMRAPI.LastRunJobFlowInput.Instances.InstanceFleets
+	var names []string
+	for _, f := range fleets {
+		names = append(names, aws.StringValue(f.Name))
+	}
+	if exp := []string{"master", "core"}; !reflect.DeepEqual(exp, names) {
+		t.Errorf("%v expected but got %v", exp, names)
+	}
+}
+
+func TestResizeFleet(t *testing.T) {
+	a := NewMockApp()
+	mockInstanceFleets(a)
+
+	err := a.Resize(&AppResizeOptions{
+		Name:              "test",
+		InstanceGroupName: "core",
+		Spot:              aws.Int64(8),
+		TemplateOptions:   TemplateOptions{Filename: "./testdata/fleet/cluster.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Resize command expected to success but failed with %s", err.Error())
+	}
+
+	exp := &emr.ModifyInstanceFleetInput{
+		ClusterId: aws.String("j-00000000"),
+		InstanceFleet: &emr.InstanceFleetModifyConfig{
+			InstanceFleetId:        aws.String("if-00000002"),
+			TargetOnDemandCapacity: aws.Int64(2),
+			TargetSpotCapacity:     aws.Int64(8),
+		},
+	}
+	if input := a.EMRAPI.LastModifyInstanceFleetInput; !reflect.DeepEqual(exp, input) {
+		t.Errorf("%s expected but got %s", exp, input)
+	}
+	if msg := a.Stderr.String(); !strings.Contains(msg, "resizing core to 2 on-demand and 8 spot units...") {
+		t.Errorf("resizing message expected but got '%s'", msg)
+	}
+}
+
+func TestResizeFleetNew(t *testing.T) {
+	a := NewMockApp()
+	mockInstanceFleets(a)
+
+	err := a.Resize(&AppResizeOptions{
+		Name:              "test",
+		InstanceGroupName: "task",
+		Spot:              aws.Int64(16),
+		TemplateOptions:   TemplateOptions{Filename: "./testdata/fleet/cluster.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Resize command expected to success but failed with %s", err.Error())
+	}
+
+	input := a.EMRAPI.LastAddInstanceFleetInput
+	if input == nil {
+		t.Fatalf("AddInstanceFleet API is expected to be called but not")
+	}
+	if n := aws.Int64Value(input.InstanceFleet.TargetSpotCapacity); 16 != n {
+		t.Errorf("16 expected but got %d", n)
+	}
+	if typ := aws.StringValue(input.InstanceFleet.InstanceTypeConfigs[0].InstanceType); "r5.xlarge" != typ {
+		t.Errorf("r5.xlarge expected but got %s", typ)
+	}
+}
+
+func TestResizeFleetWithSize(t *testing.T) {
+	a := NewMockApp()
+	mockInstanceFleets(a)
+
+	err := a.Resize(&AppResizeOptions{
+		Name:              "test",
+		InstanceGroupName: "core",
+		Size:              "4",
+		TemplateOptions:   TemplateOptions{Filename: "./testdata/fleet/cluster.yml"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "--on-demand and --spot") {
+		t.Errorf("error for SIZE of an instance fleet expected but got %v", err)
+	}
+}
+
+func TestWaitFleetStaleTargets(t *testing.T) {
+	ResizePollInterval = time.Millisecond
+	a := NewMockApp()
+	n := 0
+	// the fleet reports the old targets as provisioned until the resize is registered
+	a.EMRAPI.MockListInstanceFleetsPages = func(input *emr.ListInstanceFleetsInput, fn func(*emr.ListInstanceFleetsOutput, bool) bool) error {
+		n += 1
+		target, provisioned := int64(1), int64(1)
+		if n > 1 {
+			target = 4
+		}
+		if n > 2 {
+			provisioned = 4
+		}
+		fn(&emr.ListInstanceFleetsOutput{InstanceFleets: []*emr.InstanceFleet{{
+			Id:                          aws.String("if-00000002"),
+			Name:                        aws.String("core"),
+			TargetOnDemandCapacity:      aws.Int64(2),
+			ProvisionedOnDemandCapacity: aws.Int64(2),
+			TargetSpotCapacity:          aws.Int64(target),
+			ProvisionedSpotCapacity:     aws.Int64(provisioned),
+			Status:                      &emr.InstanceFleetStatus{State: aws.String(emr.InstanceFleetStateRunning)},
+		}}}, true)
+		return nil
+	}
+
+	err := a.WaitInstanceFleet("j-00000000", "if-00000002", 2, 4, time.Minute)
+	if err != nil {
+		t.Fatalf("WaitInstanceFleet expected to success but failed with %s", err.Error())
+	}
+	if 3 != n {
+		t.Errorf("3 polls expected but got %d", n)
+	}
+}
+
+func TestListFleets(t *testing.T) {
+	a := NewMockApp()
+	mockInstanceFleets(a)
+
+	err := a.List(&AppListOptions{
+		NoMaster:  true,
+		NoMetrics: true,
+		Limit:     10,
+	})
+	if err != nil {
+		t.Fatalf("List command expected to success but failed with %s", err.Error())
+	}
+
+	exp := "  Nodes:\n" +
+		"    master: 1 on-demand, 0 spot\n" +
+		"    core: 2 on-demand, 1(4) spot\n"
+	if out := a.Stdout.String(); !strings.Contains(out, exp) {
+		t.Errorf("'%s' expected but got '%s'", exp, out)
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"crypto/rand"
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+/*
+ * Template functions
+ *
+ * Functions taking a value take it as the last argument so that they can be used in pipelines:
+ *   {{ lookup "core" 1 | int }}
+ */
+func templateFuncs() template.FuncMap {
+	return template.FuncMap{
+		// types
+		"int":     toInt,
+		"bool":    toBool,
+		"list":    toList,
+		"default": defaultValue,
+
+		// strings
+		"upper":   strings.ToUpper,
+		"lower":   strings.ToLower,
+		"trim":    strings.TrimSpace,
+		"replace": func(old, new, s string) string { return strings.Replace(s, old, new, -1) },
+		"join":    join,
+
+		// time
+		"now":  time.Now,
+		"date": func(layout string, t time.Time) string { return t.Format(layout) },
+
+		"uuid": uuid,
+
+		// structured values
+		"toYaml": toYaml,
+		"indent": indent,
+	}
+}
+
+func toInt(v interface{}) (int, error) {
+	switch t := v.(type) {
+	case int:
+		return t, nil
+	case int64:
+		return int(t), nil
+	case float64:
+		return int(t), nil
+	case string:
+		i, err := strconv.Atoi(strings.TrimSpace(t))
+		if err != nil {
+			return 0, fmt.Errorf("%q is not an integer", t)
+		}
+		return i, nil
+	default:
+		return 0, fmt.Errorf("%v is not an integer", v)
+	}
+}
+
+func toBool(v interface{}) (bool, error) {
+	switch t := v.(type) {
+	case bool:
+		return t, nil
+	case string:
+		b, err := strconv.ParseBool(strings.TrimSpace(t))
+		if err != nil {
+			return false, fmt.Errorf("%q is not a boolean", t)
+		}
+		return b, nil
+	default:
+		return false, fmt.Errorf("%v is not a boolean", v)
+	}
+}
+
+// toList splits a comma separated string. Lists are returned as is.
+func toList(v interface{}) []interface{} {
+	if s, ok := v.(string); ok {
+		var ret []interface{}
+		for _, e := range strings.Split(s, ",") {
+			if e = strings.TrimSpace(e); e != "" {
+				ret = append(ret, e)
+			}
+		}
+		return ret
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() == reflect.Slice || rv.Kind() == reflect.Array {
+		ret := make([]interface{}, rv.Len())
+		for i := range ret {
+			ret[i] = rv.Index(i).Interface()
+		}
+		return ret
+	}
+
+	if v == nil {
+		return nil
+	}
+	return []interface{}{v}
+}
+
+// defaultValue returns v unless it is empty (nil, zero or an empty string or list).
+func defaultValue(def interface{}, v interface{}) interface{} {
+	if v == nil {
+		return def
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		if rv.Len() == 0 {
+			return def
+		}
+	case reflect.Bool:
+		if !rv.Bool() {
+			return def
+		}
+	case reflect.Int, reflect.Int64:
+		if rv.Int() == 0 {
+			return def
+		}
+	case reflect.Float64:
+		if rv.Float() == 0 {
+			return def
+		}
+	}
+	return v
+}
+
+func join(sep string, v interface{}) string {
+	var ss []string
+	for _, e := range toList(v) {
+		ss = append(ss, fmt.Sprint(e))
+	}
+	return strings.Join(ss, sep)
+}
+
+// uuid returns a random (version 4) UUID.
+func uuid() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
+func toYaml(v interface{}) (string, error) {
+	dat, err := yaml.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSuffix(string(dat), "\n"), nil
+}
+
+// indent prefixes every line of s with n spaces.
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	return pad + strings.Replace(s, "\n", "\n"+pad, -1)
+}
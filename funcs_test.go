@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func renderString(t *testing.T, text string, vars map[string]string) (string, error) {
+	l := newConfigLoader("test", vars)
+	tmpl, err := template.New("test").Funcs(l.funcMap("test.yml")).Parse(text)
+	if err != nil {
+		t.Fatalf("template %s is invalid: %s", text, err.Error())
+	}
+	buf := bytes.NewBufferString("")
+	err = tmpl.Execute(buf, vars)
+	return buf.String(), err
+}
+
+/*
+ * Test template functions
+ */
+func TestTemplateFuncs(t *testing.T) {
+	vars := map[string]string{
+		"core":  "3",
+		"spot":  "true",
+		"apps":  "Hadoop, Hive,Spark",
+		"empty": "",
+	}
+
+	cases := []struct {
+		text string
+		exp  string
+	}{
+		{`{{ lookup "core" 1 | int }}`, "3"},
+		{`{{ if lookup "spot" false | bool }}SPOT{{ else }}ON_DEMAND{{ end }}`, "SPOT"},
+		{`{{ range lookup "apps" "" | list }}[{{ . }}]{{ end }}`, "[Hadoop][Hive][Spark]"},
+		{`{{ lookup "apps" "" | join "/" }}`, "Hadoop/Hive/Spark"},
+		{`{{ lookup "empty" "" | default "x" }}`, "x"},
+		{`{{ lookup "core" "" | default "x" }}`, "3"},
+		{`{{ name | upper }}`, "TEST"},
+		{`{{ "a.b.c" | replace "." "-" }}`, "a-b-c"},
+		{`{{ required "core" }}`, "3"},
+		{`{{ toYaml (list "a,b") | indent 2 }}`, "  - a\n  - b"},
+	}
+	for _, c := range cases {
+		out, err := renderString(t, c.text, vars)
+		if err != nil {
+			t.Errorf("%s expected to success but failed with %s", c.text, err.Error())
+			continue
+		}
+		if c.exp != out {
+			t.Errorf("%s: '%s' expected but got '%s'", c.text, c.exp, out)
+		}
+	}
+}
+
+func TestTemplateFuncsRequired(t *testing.T) {
+	_, err := renderString(t, `{{ required "subnet" }}`, map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), "variable subnet is required") {
+		t.Errorf("required error expected but got %v", err)
+	}
+}
+
+func TestTemplateFuncsInt(t *testing.T) {
+	_, err := renderString(t, `{{ lookup "core" "x" | int }}`, map[string]string{})
+	if err == nil || !strings.Contains(err.Error(), `"x" is not an integer`) {
+		t.Errorf("type error expected but got %v", err)
+	}
+}
+
+func TestTemplateFuncsUUIDAndDate(t *testing.T) {
+	out, err := renderString(t, `{{ uuid }} {{ now | date "2006" }}`, map[string]string{})
+	if err != nil {
+		t.Fatalf("template expected to success but failed with %s", err.Error())
+	}
+	if !regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12} \d{4}$`).MatchString(out) {
+		t.Errorf("uuid and year expected but got '%s'", out)
+	}
+}
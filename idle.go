@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"strings"
+	"time"
+)
+
+/*
+ * Idle auto-termination
+ *
+ * `idle_timeout: 2h` in a template terminates the cluster after it has been idle for 2 hours.
+ */
+
+// Limits of the idle timeout accepted by PutAutoTerminationPolicy.
+const (
+	MinIdleTimeout = time.Minute
+	MaxIdleTimeout = 7 * 24 * time.Hour
+)
+
+func parseIdleTimeout(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid idle timeout %s (e.g. 30m or 2h expected)", s)
+	}
+	if d < MinIdleTimeout || d > MaxIdleTimeout {
+		return 0, fmt.Errorf("idle timeout %s is out of range %s-%s", s, MinIdleTimeout, MaxIdleTimeout)
+	}
+	return d, nil
+}
+
+func validateIdleTimeout(timeout string, config *emr.RunJobFlowInput, dat []byte) []*ConfigError {
+	if timeout == "" {
+		return nil
+	}
+	lines := strings.Split(string(dat), "\n")
+	if config.AutoTerminationPolicy != nil {
+		return []*ConfigError{newConfigError(lines, keyPattern("idle_timeout"), 1,
+			"idle_timeout: autoterminationpolicy is set as well, use either of them")}
+	}
+	if _, err := parseIdleTimeout(timeout); err != nil {
+		return []*ConfigError{newConfigError(lines, keyPattern("idle_timeout"), 1, "idle_timeout: "+err.Error())}
+	}
+	return nil
+}
+
+// setIdleTimeout puts the auto-termination policy into the RunJobFlow request,
+// so that the cluster has it even if starting fails halfway.
+func setIdleTimeout(config *emr.RunJobFlowInput, timeout string) error {
+	d, err := parseIdleTimeout(timeout)
+	if err != nil {
+		return err
+	}
+	config.AutoTerminationPolicy = &emr.AutoTerminationPolicy{IdleTimeout: aws.Int64(int64(d.Seconds()))}
+	return nil
+}
+
+// putIdleTimeout puts the auto-termination policy to the running cluster.
+func (s *App) putIdleTimeout(id string, timeout string) error {
+	d, err := parseIdleTimeout(timeout)
+	if err != nil {
+		return err
+	}
+
+	in := emr.PutAutoTerminationPolicyInput{
+		ClusterId:             aws.String(id),
+		AutoTerminationPolicy: &emr.AutoTerminationPolicy{IdleTimeout: aws.Int64(int64(d.Seconds()))},
+	}
+	_, err = s.EMRAPI.PutAutoTerminationPolicy(&in)
+	if err != nil {
+		return apiError("PutAutoTerminationPolicy", err)
+	}
+	fmt.Fprintf(s.Stderr, "idle timeout: %s\n", d)
+	return nil
+}
+
+/*
+ * IDLE command
+ */
+type AppIdleOptions struct {
+	Name   string
+	Set    string
+	Remove bool
+}
+
+// Idle sets, removes or shows the idle timeout of the cluster.
+func (s *App) Idle(o *AppIdleOptions) error {
+	id, err := s.FindClusterId(o.Name)
+	if err != nil {
+		return err
+	}
+
+	if o.Set != "" {
+		return s.putIdleTimeout(id, o.Set)
+	}
+
+	if o.Remove {
+		_, err := s.EMRAPI.RemoveAutoTerminationPolicy(&emr.RemoveAutoTerminationPolicyInput{ClusterId: aws.String(id)})
+		if err != nil {
+			return apiError("RemoveAutoTerminationPolicy", err)
+		}
+		fmt.Fprintf(s.Stderr, "removed idle timeout of %s\n", o.Name)
+		return nil
+	}
+
+	out, err := s.EMRAPI.GetAutoTerminationPolicy(&emr.GetAutoTerminationPolicyInput{ClusterId: aws.String(id)})
+	if err != nil {
+		return apiError("GetAutoTerminationPolicy", err)
+	}
+	if p := out.AutoTerminationPolicy; p != nil && p.IdleTimeout != nil {
+		fmt.Fprintln(s.Stdout, time.Duration(aws.Int64Value(p.IdleTimeout))*time.Second)
+	} else {
+		fmt.Fprintln(s.Stdout, "none")
+	}
+	return nil
+}
+
+/*
+ * Idle time
+ */
+
+type yarnAllAppsBuffer struct {
+	Apps struct {
+		App []struct {
+			State        string `json:"state"`
+			FinishedTime int64  `json:"finishedTime"` // milliseconds
+		} `json:"app"`
+	} `json:"apps"`
+}
+
+// ClusterActivity is what the cluster is doing, or since when it has been idle.
+type ClusterActivity struct {
+	RunningApps  int
+	RunningSteps int
+	IdleSince    time.Time // zero if unknown
+}
+
+func (a *ClusterActivity) String() string {
+	if a.RunningApps > 0 || a.RunningSteps > 0 {
+		return fmt.Sprintf("no (%d YARN apps, %d steps running)", a.RunningApps, a.RunningSteps)
+	}
+	if a.IdleSince.IsZero() {
+		return "unknown"
+	}
+	return fmt.Sprintf("%s (since %s)", formatAge(time.Since(a.IdleSince)), a.IdleSince.Format(time.RFC3339))
+}
+
+// getClusterActivity reads the YARN applications and the latest steps of the cluster.
+// The cluster is idle since the last application or step finished, or since it got ready.
+func (s *App) getClusterActivity(id string, master string, ready time.Time) (*ClusterActivity, error) {
+	ret := &ClusterActivity{IdleSince: ready}
+
+	url := fmt.Sprintf("http://%s:8088/ws/v1/cluster/apps", master)
+	buf, err := s.OpHandler.HttpGet(url)
+	if err != nil {
+		return nil, &UnreachableError{URL: url, Err: err}
+	}
+	dat := yarnAllAppsBuffer{}
+	err = json.Unmarshal(buf, &dat)
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range dat.Apps.App {
+		switch app.State {
+		case "FINISHED", "FAILED", "KILLED":
+			if t := time.Unix(0, app.FinishedTime*int64(time.Millisecond)); app.FinishedTime > 0 && t.After(ret.IdleSince) {
+				ret.IdleSince = t
+			}
+		default:
+			ret.RunningApps++
+		}
+	}
+
+	// steps are listed newest first, so the first page has the last finished one
+	in := emr.ListStepsInput{ClusterId: aws.String(id)}
+	err = s.EMRAPI.ListStepsPages(&in, func(out *emr.ListStepsOutput, b bool) bool {
+		for _, step := range out.Steps {
+			if step.Status == nil {
+				continue
+			}
+			switch aws.StringValue(step.Status.State) {
+			case emr.StepStatePending, emr.StepStateRunning:
+				ret.RunningSteps++
+			}
+			if tl := step.Status.Timeline; tl != nil && aws.TimeValue(tl.EndDateTime).After(ret.IdleSince) {
+				ret.IdleSince = aws.TimeValue(tl.EndDateTime)
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return nil, apiError("ListSteps", err)
+	}
+
+	return ret, nil
+}
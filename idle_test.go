@@ -0,0 +1,172 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+ * Test Idle
+ */
+func TestStartIdleTimeout(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test-cluster",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/idle/cluster.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Start command expected to success but failed with %s", err.Error())
+	}
+
+	policy := a.EMRAPI.LastRunJobFlowInput.AutoTerminationPolicy
+	if policy == nil {
+		t.Fatalf("auto-termination policy is expected in RunJobFlow request")
+	}
+	if n := aws.Int64Value(policy.IdleTimeout); 7200 != n {
+		t.Errorf("7200 expected but got %d", n)
+	}
+	if a.EMRAPI.LastPutAutoTerminationPolicyInput != nil {
+		t.Errorf("PutAutoTerminationPolicy API is expected not to be called after launch")
+	}
+}
+
+func TestIdleTimeoutWithAutoTerminationPolicy(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Validate(&AppValidateOptions{
+		Name:            "test",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/idle/both.yml"},
+	})
+	if _, ok := err.(*ValidationError); !ok {
+		t.Fatalf("ValidationError expected but got %v", err)
+	}
+	if exp := "idle_timeout: autoterminationpolicy is set as well"; !strings.Contains(err.Error(), exp) {
+		t.Errorf("'%s' expected in '%s'", exp, err.Error())
+	}
+}
+
+func TestIdleTimeoutInvalid(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Validate(&AppValidateOptions{
+		Name:            "test",
+		Vars:            map[string]string{"idle": "30s"},
+		TemplateOptions: TemplateOptions{Filename: "./testdata/idle/cluster.yml"},
+	})
+	if _, ok := err.(*ValidationError); !ok {
+		t.Fatalf("ValidationError expected but got %v", err)
+	}
+	if exp := "idle_timeout: idle timeout 30s is out of range 1m0s-168h0m0s"; !strings.Contains(err.Error(), exp) {
+		t.Errorf("'%s' expected in '%s'", exp, err.Error())
+	}
+}
+
+func TestIdle(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Idle(&AppIdleOptions{Name: "test", Set: "90m"})
+	if err != nil {
+		t.Fatalf("Idle command expected to success but failed with %s", err.Error())
+	}
+	if n := aws.Int64Value(a.EMRAPI.LastPutAutoTerminationPolicyInput.AutoTerminationPolicy.IdleTimeout); 5400 != n {
+		t.Errorf("5400 expected but got %d", n)
+	}
+
+	err = a.Idle(&AppIdleOptions{Name: "test", Remove: true})
+	if err != nil {
+		t.Fatalf("Idle command expected to success but failed with %s", err.Error())
+	}
+	if a.EMRAPI.LastRemoveAutoTerminationPolicyInput == nil {
+		t.Errorf("RemoveAutoTerminationPolicy API is expected to be called")
+	}
+
+	a.EMRAPI.MockGetAutoTerminationPolicy = func(*emr.GetAutoTerminationPolicyInput) (*emr.GetAutoTerminationPolicyOutput, error) {
+		return &emr.GetAutoTerminationPolicyOutput{
+			AutoTerminationPolicy: &emr.AutoTerminationPolicy{IdleTimeout: aws.Int64(7200)},
+		}, nil
+	}
+	err = a.Idle(&AppIdleOptions{Name: "test"})
+	if err != nil {
+		t.Fatalf("Idle command expected to success but failed with %s", err.Error())
+	}
+	if out := a.Stdout.String(); "2h0m0s\n" != out {
+		t.Errorf("'2h0m0s' expected but got '%s'", out)
+	}
+}
+
+func TestClusterActivity(t *testing.T) {
+	a := NewMockApp()
+	ready := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	finished := time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)
+	stepEnd := time.Date(2026, 10, 18, 2, 0, 0, 0, time.UTC)
+
+	apps := `{"apps": {"app": [{"state": "FINISHED", "finishedTime": %d}, {"state": "%s", "finishedTime": 0}]}}`
+	a.OpHandler.MockGet = func(url string) ([]byte, error) {
+		return []byte(fmt.Sprintf(apps, finished.UnixNano()/int64(time.Millisecond), "RUNNING")), nil
+	}
+
+	activity, err := a.getClusterActivity("j-00000000", "master", ready)
+	if err != nil {
+		t.Fatalf("getClusterActivity expected to success but failed with %s", err.Error())
+	}
+	if exp := "no (1 YARN apps, 0 steps running)"; exp != activity.String() {
+		t.Errorf("'%s' expected but got '%s'", exp, activity.String())
+	}
+
+	a.OpHandler.MockGet = func(url string) ([]byte, error) {
+		return []byte(fmt.Sprintf(apps, finished.UnixNano()/int64(time.Millisecond), "KILLED")), nil
+	}
+	a.EMRAPI.MockListStepsPages = func(input *emr.ListStepsInput, fn func(*emr.ListStepsOutput, bool) bool) error {
+		fn(&emr.ListStepsOutput{
+			Steps: []*emr.StepSummary{
+				{Status: &emr.StepStatus{
+					State:    aws.String(emr.StepStateCompleted),
+					Timeline: &emr.StepTimeline{EndDateTime: aws.Time(stepEnd)},
+				}},
+			},
+		}, true)
+		return nil
+	}
+
+	activity, err = a.getClusterActivity("j-00000000", "master", ready)
+	if err != nil {
+		t.Fatalf("getClusterActivity expected to success but failed with %s", err.Error())
+	}
+	if !stepEnd.Equal(activity.IdleSince) {
+		t.Errorf("%s expected but got %s", stepEnd, activity.IdleSince)
+	}
+}
+
+func TestListIdle(t *testing.T) {
+	a := NewMockApp()
+	ready := time.Now().Add(-2*time.Hour - 5*time.Minute - 30*time.Second)
+	a.EMRAPI.MockListClustersPages = func(input *emr.ListClustersInput, fn func(*emr.ListClustersOutput, bool) bool) error {
+		fn(&emr.ListClustersOutput{
+			Clusters: []*emr.ClusterSummary{
+				{
+					Id:   aws.String("j-00000000"),
+					Name: aws.String("test"),
+					Status: &emr.ClusterStatus{
+						State:    aws.String(emr.ClusterStateWaiting),
+						Timeline: &emr.ClusterTimeline{ReadyDateTime: aws.Time(ready)},
+					},
+				},
+			},
+		}, true)
+		return nil
+	}
+
+	err := a.List(&AppListOptions{NoClusterSize: true})
+	if err != nil {
+		t.Fatalf("List command expected to success but failed with %s", err.Error())
+	}
+
+	if exp, out := "  Idle: 2h5m (since ", a.Stdout.String(); !strings.Contains(out, exp) {
+		t.Errorf("'%s' expected in '%s'", exp, out)
+	}
+}
@@ -2,36 +2,61 @@ package main
 
 import (
 	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
 	"gopkg.in/urfave/cli.v1"
 	"os"
 	"path"
-	"strconv"
+	"regexp"
 	"strings"
+	"time"
 )
 
 func main() {
-	BuildCLI(NewApp()).Run(os.Args)
+	app := BuildCLI(NewApp())
+	app.Run(escapeNegativeSize(app, os.Args))
 }
 
 func BuildCLI(a *App) *cli.App {
 	app := cli.NewApp()
 	app.Usage = "An EMR utility command"
+	app.EnableBashCompletion = true
+	app.Description = "Exit codes: 1 error, 3 not found, 4 ambiguous name, 5 AWS API error, 6 cluster unreachable, 7 invalid cluster config, 8 timeout, 9 diff found drift"
 	app.Commands = []cli.Command{
 		{
-			Name:      "start",
-			Aliases:   []string{"up"},
-			Usage:     "start new EMR cluster",
-			ArgsUsage: "NAME [KEY=VAL ...]",
-			Flags: []cli.Flag{
+			Name:         "start",
+			Aliases:      []string{"up"},
+			Usage:        "start new EMR cluster",
+			ArgsUsage:    "NAME [KEY=VAL ...]",
+			BashComplete: completeFlags,
+			Flags: append(templateFlags(),
+				cli.BoolFlag{
+					Name: "dryrun, n",
+				},
 				cli.StringFlag{
-					Name:   "filename, f",
-					Value:  path.Join(os.Getenv("HOME"), ".emrcmd-cluster.yml"),
-					EnvVar: "EMR_CLUSTER_CONFIG_FILE",
+					Name:  "output, o",
+					Value: "yaml",
+					Usage: "dry-run output format (yaml, or json for aws emr create-cluster --cli-input-json)",
 				},
 				cli.BoolFlag{
-					Name: "dryrun, n",
+					Name:  "explain",
+					Usage: "show template variables and where their values came from",
 				},
-			},
+				cli.DurationFlag{
+					Name:  "ttl",
+					Usage: "tag the cluster with expires-at after `DURATION` (e.g. 8h), which reap terminates",
+				},
+				cli.StringFlag{
+					Name:   "prices",
+					Value:  DefaultPriceFile,
+					EnvVar: "EMR_PRICE_FILE",
+					Usage:  "price table for the estimated cost printed in dry-run",
+				},
+				cli.BoolFlag{
+					Name:   "pricing-api",
+					EnvVar: "EMR_PRICING_API",
+					Usage:  "look up prices missing in the price table with the AWS Price List API",
+				},
+			),
 			Action: func(c *cli.Context) error {
 				validateArgsLength(c, 1, -1)
 
@@ -41,14 +66,20 @@ func BuildCLI(a *App) *cli.App {
 				vars["name"] = name
 
 				err := a.Start(&AppStartOptions{
-					Name:     name,
-					Vars:     vars,
-					Filename: c.String("filename"),
-					DryRun:   c.Bool("dryrun"),
+					Name:            name,
+					Vars:            vars,
+					TemplateOptions: templateOptions(c),
+					DryRun:          c.Bool("dryrun"),
+					Output:          c.String("output"),
+					Explain:         c.Bool("explain"),
+					TTL:             c.Duration("ttl"),
+
+					PriceFile:  c.String("prices"),
+					PricingAPI: c.Bool("pricing-api"),
 				})
 
 				if err != nil {
-					return cli.NewExitError(err, 1)
+					return exitError(err)
 				}
 
 				return nil
@@ -56,9 +87,10 @@ func BuildCLI(a *App) *cli.App {
 		},
 
 		{
-			Name:    "list",
-			Aliases: []string{"ls"},
-			Usage:   "list EMR clusters",
+			Name:         "list",
+			Aliases:      []string{"ls"},
+			Usage:        "list EMR clusters",
+			BashComplete: completeFlags,
 			Flags: []cli.Flag{
 				cli.BoolFlag{
 					Name: "all, a",
@@ -93,76 +125,716 @@ func BuildCLI(a *App) *cli.App {
 				})
 
 				if err != nil {
-					return cli.NewExitError(err, 1)
+					return exitError(err)
 				}
 
 				return nil
 			},
 		},
 		{
-			Name:      "resize",
-			Usage:     "resize an EMR instance group",
-			ArgsUsage: "NAME INSTANCE_GROUP_NAME SIZE [KEY=VAL ...]",
-			Flags: []cli.Flag{
+			Name:         "resize",
+			Usage:        "resize an EMR instance group or instance fleet",
+			ArgsUsage:    "NAME INSTANCE_GROUP_NAME SIZE|+N|-N|xN|N% [KEY=VAL ...]\n   emrcmd resize NAME FLEET_NAME --on-demand N --spot M [KEY=VAL ...]",
+			Description:  "SIZE relative to the current size (+4, -2, x2 or 50%) is also accepted.",
+			BashComplete: completeClusterAndInstanceGroupName(a),
+			Flags: append(templateFlags(),
+				cli.BoolFlag{
+					Name: "dryrun, n",
+				},
 				cli.StringFlag{
-					Name:   "filename, f",
-					Value:  path.Join(os.Getenv("HOME"), ".emrcmd-cluster.yml"),
-					EnvVar: "EMR_CLUSTER_CONFIG_FILE",
+					Name:  "output, o",
+					Value: "yaml",
+					Usage: "dry-run output format (yaml or json)",
 				},
 				cli.BoolFlag{
-					Name: "dryrun, n",
+					Name:  "explain",
+					Usage: "show template variables and where their values came from",
+				},
+				cli.IntFlag{
+					Name:  "min",
+					Usage: "lower limit of the new size (default: autoscalingpolicy constraints in the template)",
+				},
+				cli.IntFlag{
+					Name:  "max",
+					Usage: "upper limit of the new size (default: autoscalingpolicy constraints in the template)",
 				},
+				cli.BoolFlag{
+					Name:  "wait, w",
+					Usage: "wait until the requested instances are running",
+				},
+				cli.DurationFlag{
+					Name:  "timeout",
+					Value: 30 * time.Minute,
+					Usage: "how long to wait with --wait (0 waits forever)",
+				},
+				cli.BoolFlag{
+					Name:  "graceful",
+					Usage: "shrink by decommissioning the least busy nodes and protecting ApplicationMasters, then wait",
+				},
+				cli.DurationFlag{
+					Name:  "decommission-timeout",
+					Value: 20 * time.Minute,
+					Usage: "how long YARN waits for running containers on the nodes to shrink with --graceful",
+				},
+				cli.IntFlag{
+					Name:  "on-demand",
+					Usage: "target on-demand capacity of an instance fleet",
+				},
+				cli.IntFlag{
+					Name:  "spot",
+					Usage: "target spot capacity of an instance fleet",
+				},
+			),
+			Action: func(c *cli.Context) error {
+				// instance fleets are resized with --on-demand and --spot instead of SIZE
+				var onDemand, spot *int64
+				if c.IsSet("on-demand") {
+					onDemand = aws.Int64(int64(c.Int("on-demand")))
+				}
+				if c.IsSet("spot") {
+					spot = aws.Int64(int64(c.Int("spot")))
+				}
+
+				var size string
+				var args []string
+				if onDemand != nil || spot != nil {
+					validateArgsLength(c, 2, -1)
+					args = withoutTerminator(c.Args())
+				} else {
+					validateArgsLength(c, 3, -1)
+					args = withoutTerminator(c.Args())
+					size = args[2]
+					args = append(args[:2], args[3:]...)
+				}
+
+				name := args[0]
+				instanceGroupName := args[1]
+				vars := parseVariables(args[2:])
+				vars["name"] = name
+
+				err := a.Resize(&AppResizeOptions{
+					Name:                name,
+					InstanceGroupName:   instanceGroupName,
+					Size:                size,
+					Min:                 c.Int("min"),
+					Max:                 c.Int("max"),
+					Wait:                c.Bool("wait"),
+					Timeout:             c.Duration("timeout"),
+					Graceful:            c.Bool("graceful"),
+					DecommissionTimeout: c.Duration("decommission-timeout"),
+					OnDemand:            onDemand,
+					Spot:                spot,
+					Vars:                vars,
+					TemplateOptions:     templateOptions(c),
+					DryRun:              c.Bool("dryrun"),
+					Output:              c.String("output"),
+					Explain:             c.Bool("explain"),
+				})
+
+				if err != nil {
+					return exitError(err)
+				}
+
+				return nil
 			},
+		},
+		{
+			Name:         "validate",
+			Usage:        "validate cluster config",
+			ArgsUsage:    "[KEY=VAL ...]",
+			BashComplete: completeFlags,
+			Flags: append(templateFlags(),
+				cli.StringFlag{
+					Name:  "name",
+					Value: "validate",
+					Usage: "cluster name used to render the template",
+				},
+			),
 			Action: func(c *cli.Context) error {
-				validateArgsLength(c, 3, -1)
+				name := c.String("name")
+				vars := parseVariables(c.Args())
+				vars["name"] = name
 
-				name := c.Args().Get(0)
-				instanceGroupName := c.Args().Get(1)
-				size, err := strconv.Atoi(c.Args().Get(2))
+				err := a.Validate(&AppValidateOptions{
+					Name:            name,
+					Vars:            vars,
+					TemplateOptions: templateOptions(c),
+				})
 				if err != nil {
-					return cli.NewExitError(err, 1)
+					return exitError(err)
 				}
-				vars := parseVariables(c.Args()[3:])
+
+				return nil
+			},
+		},
+		{
+			Name:         "vars",
+			Usage:        "print template variables merged from arguments, var files, environment and template",
+			ArgsUsage:    "[KEY=VAL ...]",
+			BashComplete: completeFlags,
+			Flags: append(templateFlags(),
+				cli.StringFlag{
+					Name:  "name",
+					Value: "vars",
+					Usage: "cluster name used to render the template",
+				},
+			),
+			Action: func(c *cli.Context) error {
+				name := c.String("name")
+				vars := parseVariables(c.Args())
 				vars["name"] = name
 
-				err = a.Resize(&AppResizeOptions{
+				err := a.Vars(&AppVarsOptions{
+					Name:            name,
+					Vars:            vars,
+					TemplateOptions: templateOptions(c),
+				})
+				if err != nil {
+					return exitError(err)
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:         "profiles",
+			Usage:        "list cluster config profiles",
+			BashComplete: completeFlags,
+			Action: func(c *cli.Context) error {
+				validateArgsLength(c, 0, 0)
+
+				err := a.Profiles()
+				if err != nil {
+					return exitError(err)
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:         "export",
+			Usage:        "print the configuration of a running cluster as a cluster config template",
+			ArgsUsage:    "NAME|ID",
+			BashComplete: completeClusterName(a),
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "output, o",
+					Value: "yaml",
+					Usage: "output format (yaml or json)",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				validateArgsLength(c, 1, 1)
+
+				err := a.Export(&AppExportOptions{
+					Name:   c.Args().Get(0),
+					Output: c.String("output"),
+				})
+				if err != nil {
+					return exitError(err)
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:         "diff",
+			Usage:        "show differences between a running cluster and its cluster config",
+			ArgsUsage:    "NAME [KEY=VAL ...]",
+			BashComplete: completeClusterName(a),
+			Flags:        templateFlags(),
+			Action: func(c *cli.Context) error {
+				validateArgsLength(c, 1, -1)
+
+				args := c.Args()
+				name := args[0]
+				vars := parseVariables(args[1:])
+				vars["name"] = name
+
+				err := a.Diff(&AppDiffOptions{
+					Name:            name,
+					Vars:            vars,
+					TemplateOptions: templateOptions(c),
+				})
+				if err != nil {
+					return exitError(err)
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:         "apply",
+			Usage:        "resize all instance groups to the sizes in the cluster config",
+			ArgsUsage:    "NAME [KEY=VAL ...]",
+			BashComplete: completeClusterName(a),
+			Flags: append(templateFlags(),
+				cli.BoolFlag{
+					Name:  "auto-approve",
+					Usage: "apply the plan without confirmation",
+				},
+			),
+			Action: func(c *cli.Context) error {
+				validateArgsLength(c, 1, -1)
+
+				args := c.Args()
+				name := args[0]
+				vars := parseVariables(args[1:])
+				vars["name"] = name
+
+				err := a.Apply(&AppApplyOptions{
+					Name:            name,
+					Vars:            vars,
+					TemplateOptions: templateOptions(c),
+					AutoApprove:     c.Bool("auto-approve"),
+				})
+				if err != nil {
+					return exitError(err)
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:         "autoscale",
+			Usage:        "resize an instance group by YARN metrics until interrupted",
+			ArgsUsage:    "NAME [KEY=VAL ...]",
+			BashComplete: completeClusterName(a),
+			Flags: append(templateFlags(),
+				cli.StringFlag{
+					Name:  "group, g",
+					Value: "task",
+					Usage: "instance group to resize",
+				},
+				cli.IntFlag{
+					Name:  "min",
+					Value: 0,
+				},
+				cli.IntFlag{
+					Name:  "max",
+					Value: 10,
+				},
+				cli.IntFlag{
+					Name:  "step",
+					Value: 2,
+					Usage: "instances added or removed at once",
+				},
+				cli.IntFlag{
+					Name:  "scale-in-memory",
+					Value: 50,
+					Usage: "shrink when memory used (%) is under this and no containers are pending",
+				},
+				cli.IntFlag{
+					Name:  "checks",
+					Value: 3,
+					Usage: "consecutive polls required to scale out or in",
+				},
+				cli.DurationFlag{
+					Name:  "interval",
+					Value: time.Minute,
+					Usage: "how often the metrics are polled",
+				},
+				cli.DurationFlag{
+					Name:  "cooldown",
+					Value: 10 * time.Minute,
+					Usage: "no resize within this period after a resize",
+				},
+				cli.BoolFlag{
+					Name:  "dryrun, n",
+					Usage: "log decisions without resizing",
+				},
+			),
+			Action: func(c *cli.Context) error {
+				validateArgsLength(c, 1, -1)
+
+				args := c.Args()
+				name := args[0]
+				vars := parseVariables(args[1:])
+				vars["name"] = name
+
+				err := a.Autoscale(&AppAutoscaleOptions{
 					Name:              name,
-					InstanceGroupName: instanceGroupName,
-					Size:              size,
-					Vars:              vars,
-					Filename:          c.String("filename"),
+					InstanceGroupName: c.String("group"),
+					Min:               c.Int("min"),
+					Max:               c.Int("max"),
+					Step:              c.Int("step"),
+					ScaleInMemory:     c.Int("scale-in-memory"),
+					Checks:            c.Int("checks"),
+					Interval:          c.Duration("interval"),
+					Cooldown:          c.Duration("cooldown"),
 					DryRun:            c.Bool("dryrun"),
+					Vars:              vars,
+					TemplateOptions:   templateOptions(c),
 				})
-
 				if err != nil {
-					return cli.NewExitError(err, 1)
+					return exitError(err)
 				}
 
 				return nil
 			},
 		},
 		{
-			Name:      "terminate",
-			Aliases:   []string{"rm", "down"},
-			Usage:     "terminate EMR cluster",
-			ArgsUsage: "NAME",
+			Name:  "scheduler",
+			Usage: "resize or terminate clusters by the schedule section of the cluster config",
+			Subcommands: []cli.Command{
+				{
+					Name:         "run",
+					Usage:        "apply the schedule to the clusters until interrupted",
+					ArgsUsage:    "NAME [NAME ...] [KEY=VAL ...]",
+					BashComplete: completeClusterName(a),
+					Flags: append(templateFlags(),
+						cli.StringFlag{
+							Name:  "state",
+							Value: path.Join(os.Getenv("HOME"), ".emrcmd", "scheduler-state.json"),
+							Usage: "file recording when each rule was applied",
+						},
+						cli.DurationFlag{
+							Name:  "interval",
+							Value: time.Minute,
+							Usage: "how often the schedule is evaluated",
+						},
+						cli.DurationFlag{
+							Name:  "max-delay",
+							Value: time.Hour,
+							Usage: "skip rules which were due longer ago than this, e.g. after the scheduler was down",
+						},
+						cli.BoolFlag{
+							Name:  "dryrun, n",
+							Usage: "log due rules without applying them",
+						},
+					),
+					Action: func(c *cli.Context) error {
+						validateArgsLength(c, 1, -1)
+
+						var names, kvs []string
+						for _, arg := range c.Args() {
+							if strings.Contains(arg, "=") {
+								kvs = append(kvs, arg)
+							} else {
+								names = append(names, arg)
+							}
+						}
+						if len(names) == 0 {
+							fmt.Fprintln(cli.ErrWriter, "Error: at least 1 cluster name expected")
+							cli.OsExiter(1)
+						}
+
+						err := a.SchedulerRun(&AppSchedulerOptions{
+							Names:           names,
+							Vars:            parseVariables(kvs),
+							TemplateOptions: templateOptions(c),
+							StateFile:       c.String("state"),
+							Interval:        c.Duration("interval"),
+							MaxDelay:        c.Duration("max-delay"),
+							DryRun:          c.Bool("dryrun"),
+						})
+						if err != nil {
+							return exitError(err)
+						}
+
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "scaling",
+			Usage: "get, set or remove managed scaling and auto-scaling policies",
+			Subcommands: []cli.Command{
+				{
+					Name:         "get",
+					Usage:        "print the scaling policies of a running cluster",
+					ArgsUsage:    "NAME|ID",
+					BashComplete: completeClusterName(a),
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "output, o",
+							Value: "yaml",
+							Usage: "output format (yaml or json)",
+						},
+					},
+					Action: func(c *cli.Context) error {
+						validateArgsLength(c, 1, 1)
+
+						err := a.ScalingGet(&AppScalingOptions{
+							Name:   c.Args().Get(0),
+							Output: c.String("output"),
+						})
+						if err != nil {
+							return exitError(err)
+						}
+
+						return nil
+					},
+				},
+				{
+					Name:         "set",
+					Usage:        "put the scaling policies in the scaling section of the cluster config",
+					ArgsUsage:    "NAME [KEY=VAL ...]",
+					BashComplete: completeClusterName(a),
+					Flags:        templateFlags(),
+					Action: func(c *cli.Context) error {
+						validateArgsLength(c, 1, -1)
+
+						args := c.Args()
+						name := args[0]
+						vars := parseVariables(args[1:])
+						vars["name"] = name
+
+						err := a.ScalingSet(&AppScalingOptions{
+							Name:            name,
+							Vars:            vars,
+							TemplateOptions: templateOptions(c),
+						})
+						if err != nil {
+							return exitError(err)
+						}
+
+						return nil
+					},
+				},
+				{
+					Name:         "remove",
+					Usage:        "remove the managed scaling policy and all auto-scaling policies",
+					ArgsUsage:    "NAME|ID",
+					BashComplete: completeClusterName(a),
+					Action: func(c *cli.Context) error {
+						validateArgsLength(c, 1, 1)
+
+						err := a.ScalingRemove(&AppScalingOptions{Name: c.Args().Get(0)})
+						if err != nil {
+							return exitError(err)
+						}
+
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:         "idle",
+			Usage:        "set, remove or show the idle timeout after which a cluster terminates",
+			ArgsUsage:    "NAME|ID",
+			BashComplete: completeClusterName(a),
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "set",
+					Usage: "terminate the cluster after it is idle for `DURATION` (e.g. 2h)",
+				},
+				cli.BoolFlag{
+					Name:  "remove",
+					Usage: "remove the idle timeout",
+				},
+				cli.BoolFlag{
+					Name:  "show",
+					Usage: "print the idle timeout (default)",
+				},
+			},
 			Action: func(c *cli.Context) error {
 				validateArgsLength(c, 1, 1)
 
+				n := 0
+				for _, f := range []string{"set", "remove", "show"} {
+					if c.IsSet(f) {
+						n++
+					}
+				}
+				if n > 1 {
+					fmt.Fprintln(cli.ErrWriter, "Error: only one of --set, --remove and --show expected")
+					cli.OsExiter(1)
+				}
+
+				err := a.Idle(&AppIdleOptions{
+					Name:   c.Args().Get(0),
+					Set:    c.String("set"),
+					Remove: c.Bool("remove"),
+				})
+				if err != nil {
+					return exitError(err)
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:         "terminate",
+			Aliases:      []string{"rm", "down"},
+			Usage:        "terminate EMR cluster",
+			ArgsUsage:    "NAME|ID|PATTERN [...]",
+			BashComplete: completeClusterName(a),
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "yes, y",
+					Usage: "terminate without confirmation",
+				},
+				cli.BoolFlag{
+					Name:  "force",
+					Usage: "disable termination protection before terminating",
+				},
+				cli.BoolFlag{
+					Name:  "wait, w",
+					Usage: "wait until terminated and print the final state (exit code 8 after 30 minutes)",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				validateArgsLength(c, 1, -1)
+
+				err := a.Terminate(&AppTerminateOptions{
+					Names: c.Args(),
+					Yes:   c.Bool("yes"),
+					Force: c.Bool("force"),
+					Wait:  c.Bool("wait"),
+				})
+				if err != nil {
+					return exitError(err)
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:         "reap",
+			Usage:        "report or terminate idle or expired clusters",
+			BashComplete: completeFlags,
+			Flags: []cli.Flag{
+				cli.DurationFlag{
+					Name:  "idle",
+					Value: 2 * time.Hour,
+					Usage: "reap clusters with no YARN apps or steps running for longer than this (0 to disable)",
+				},
+				cli.StringSliceFlag{
+					Name:  "keep-tag",
+					Usage: "never reap clusters with the tag `KEY[=VALUE]` (repeatable)",
+				},
+				cli.BoolFlag{
+					Name:  "terminate",
+					Usage: "terminate the clusters without confirmation instead of reporting them",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				validateArgsLength(c, 0, 0)
+
+				err := a.Reap(&AppReapOptions{
+					Idle:      c.Duration("idle"),
+					KeepTags:  c.StringSlice("keep-tag"),
+					Terminate: c.Bool("terminate"),
+				})
+				if err != nil {
+					return exitError(err)
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:         "cost",
+			Usage:        "estimate the cost of clusters from a price table",
+			ArgsUsage:    "[NAME|ID]",
+			BashComplete: completeClusterName(a),
+			Flags: []cli.Flag{
+				cli.BoolFlag{
+					Name:  "all, a",
+					Usage: "estimate all the clusters",
+				},
+				cli.StringFlag{
+					Name:  "since",
+					Value: "7d",
+					Usage: "estimate the cost in the last `DURATION` (e.g. 7d or 12h)",
+				},
+				cli.StringFlag{
+					Name:  "group-by",
+					Value: "name",
+					Usage: "group clusters by name or tag:KEY",
+				},
+				cli.StringFlag{
+					Name:   "prices",
+					Value:  DefaultPriceFile,
+					EnvVar: "EMR_PRICE_FILE",
+					Usage:  "price table in USD per instance hour",
+				},
+				cli.BoolFlag{
+					Name:   "pricing-api",
+					EnvVar: "EMR_PRICING_API",
+					Usage:  "look up prices missing in the price table with the AWS Price List API",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				validateArgsLength(c, 0, 1)
+
 				name := c.Args().Get(0)
+				if (name == "") == !c.Bool("all") {
+					fmt.Fprintln(cli.ErrWriter, "Error: either NAME or --all expected")
+					cli.OsExiter(1)
+				}
+
+				since, err := parseSince(c.String("since"))
+				if err != nil {
+					return exitError(err)
+				}
+
+				err = a.Cost(&AppCostOptions{
+					Name:       name,
+					Since:      since,
+					GroupBy:    c.String("group-by"),
+					PriceFile:  c.String("prices"),
+					PricingAPI: c.Bool("pricing-api"),
+				})
+				if err != nil {
+					return exitError(err)
+				}
+
+				return nil
+			},
+		},
+		{
+			Name:  "spot-prices",
+			Usage: "show recent spot prices of instance types per availability zone or subnet",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "types, t",
+					Usage: "comma separated instance `TYPES`, e.g. m5.xlarge,r5.2xlarge",
+				},
+				cli.StringFlag{
+					Name:  "subnets",
+					Usage: "comma separated `SUBNET` ids to show the prices in",
+				},
+				cli.StringFlag{
+					Name:  "since",
+					Value: "6h",
+					Usage: "show the minimum and maximum prices in the last `DURATION` (e.g. 1d or 6h)",
+				},
+			},
+			Action: func(c *cli.Context) error {
+				validateArgsLength(c, 0, 0)
+
+				types := splitList(c.String("types"))
+				if len(types) == 0 {
+					fmt.Fprintln(cli.ErrWriter, "Error: --types expected")
+					cli.OsExiter(1)
+				}
+
+				since, err := parseSince(c.String("since"))
+				if err != nil {
+					return exitError(err)
+				}
 
-				err := a.Terminate(name)
+				err = a.SpotPrices(&AppSpotPricesOptions{
+					Types:   types,
+					Subnets: splitList(c.String("subnets")),
+					Since:   since,
+				})
 				if err != nil {
-					return cli.NewExitError(err, 1)
+					return exitError(err)
 				}
 
 				return nil
 			},
 		},
 		{
-			Name:      "ssh",
-			Usage:     "ssh to EMR cluster",
-			ArgsUsage: "NAME [ARGS]",
+			Name:         "ssh",
+			Usage:        "ssh to EMR cluster",
+			ArgsUsage:    "NAME [ARGS]",
+			BashComplete: completeClusterName(a),
 			Flags: []cli.Flag{
 				cli.StringFlag{
 					Name:   "i",
@@ -196,16 +868,17 @@ func BuildCLI(a *App) *cli.App {
 					Debug:        c.Bool("debug"),
 				})
 				if err != nil {
-					return cli.NewExitError(err, 1)
+					return exitError(err)
 				}
 
 				return nil
 			},
 		},
 		{
-			Name:      "scp",
-			Usage:     "copy files from/to EMR cluster",
-			ArgsUsage: "NAME SOURCES... DEST",
+			Name:         "scp",
+			Usage:        "copy files from/to EMR cluster",
+			ArgsUsage:    "NAME SOURCES... DEST",
+			BashComplete: completeClusterName(a),
 			Flags: []cli.Flag{
 				cli.StringFlag{
 					Name:   "i",
@@ -239,16 +912,17 @@ func BuildCLI(a *App) *cli.App {
 					Debug:        c.Bool("debug"),
 				})
 				if err != nil {
-					return cli.NewExitError(err, 1)
+					return exitError(err)
 				}
 
 				return nil
 			},
 		},
 		{
-			Name:      "shell",
-			Usage:     "set master uri to EMR_MASTER environment variable",
-			ArgsUsage: "NAME",
+			Name:         "shell",
+			Usage:        "set master uri to EMR_MASTER environment variable",
+			ArgsUsage:    "NAME",
+			BashComplete: completeClusterName(a),
 			Action: func(c *cli.Context) error {
 				validateArgsLength(c, 1, -1)
 
@@ -257,7 +931,7 @@ func BuildCLI(a *App) *cli.App {
 
 				err := a.Shell(name, args)
 				if err != nil {
-					return cli.NewExitError(err, 1)
+					return exitError(err)
 				}
 
 				return nil
@@ -265,39 +939,34 @@ func BuildCLI(a *App) *cli.App {
 		},
 		{
 			Name:      "init",
-			Usage:     "print initialization script for shell helper",
+			Usage:     "print initialization script for shell helper and completion",
 			ArgsUsage: "[COMMAND_NAME]",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "shell, s",
+					Value: "bash",
+					Usage: "bash, zsh or fish",
+				},
+			},
+			BashComplete: func(c *cli.Context) {
+				// COMMAND_NAME is free, so shells are only offered as the value of --shell
+				switch previousArg(os.Args) {
+				case "--shell", "-s":
+					completeWords(c, []string{"bash", "zsh", "fish"})
+				default:
+					completeFlags(c)
+				}
+			},
 			Action: func(c *cli.Context) error {
 				validateArgsLength(c, 0, 1)
 
-				var name string
-				if len(c.Args()) == 1 {
-					name = c.Args().Get(0)
-				} else {
-					name = "emrcmd"
+				err := a.Init(&AppInitOptions{
+					Name:  c.Args().Get(0),
+					Shell: c.String("shell"),
+				})
+				if err != nil {
+					return exitError(err)
 				}
-				output :=
-					name + `() {
-  local command
-  command="$1"
-  if [ "$#" -gt 0 ]; then
-    shift
-  fi
-
-  case "$command" in
-  shell)
-    if [ "$#" -eq 1 ]; then
-      eval "$(command emrcmd shell "$@")"
-    else
-      command emrcmd shell "$@"
-    fi
-    ;;
-  *)
-    command emrcmd "$command" "$@";;
-  esac
-}
-`
-				fmt.Fprintln(a.Stdout, output)
 
 				return nil
 			},
@@ -308,7 +977,7 @@ func BuildCLI(a *App) *cli.App {
 }
 
 func validateArgsLength(c *cli.Context, min int, max int) {
-	l := len(c.Args())
+	l := len(withoutTerminator(c.Args()))
 
 	var n string
 	var ok bool
@@ -335,6 +1004,109 @@ func validateArgsLength(c *cli.Context, min int, max int) {
 	}
 }
 
+// templateFlags are the flags selecting the cluster config template and its variables. See templateOptions.
+func templateFlags() []cli.Flag {
+	return []cli.Flag{
+		cli.StringFlag{
+			Name:   "filename, f",
+			Value:  path.Join(os.Getenv("HOME"), ".emrcmd-cluster.yml"),
+			EnvVar: "EMR_CLUSTER_CONFIG_FILE",
+		},
+		cli.StringFlag{
+			Name:   "profile, p",
+			EnvVar: "EMR_CLUSTER_PROFILE",
+			Usage:  "use PROFILE.yml in the profile directory instead of --filename",
+		},
+		cli.StringSliceFlag{
+			Name:  "var-file",
+			Usage: "YAML file of template variables (repeatable, later files win)",
+		},
+	}
+}
+
+// templateOptions reads templateFlags.
+func templateOptions(c *cli.Context) TemplateOptions {
+	return TemplateOptions{
+		Filename: c.String("filename"),
+		Profile:  c.String("profile"),
+		VarFiles: c.StringSlice("var-file"),
+	}
+}
+
+// withoutTerminator removes `--`, which is kept in the arguments when it follows them.
+func withoutTerminator(args []string) []string {
+	var ret []string
+	for _, arg := range args {
+		if arg != "--" {
+			ret = append(ret, arg)
+		}
+	}
+	return ret
+}
+
+var negativeSizePattern = regexp.MustCompile(`^-[0-9]+$`)
+
+// escapeNegativeSize lets `resize NAME GROUP -2` be given without `--`.
+// The flags of resize are moved before `--` and the arguments after it, so that a negative size is not parsed as a flag.
+func escapeNegativeSize(app *cli.App, args []string) []string {
+	if len(args) < 3 || args[1] != "resize" || args[len(args)-1] == "--generate-bash-completion" {
+		return args
+	}
+	cmd := app.Command("resize")
+	if cmd == nil {
+		return args
+	}
+	takesValue := map[string]bool{}
+	for _, f := range cmd.Flags {
+		_, isBool := f.(cli.BoolFlag)
+		for _, name := range strings.Split(f.GetName(), ",") {
+			takesValue[strings.TrimSpace(name)] = !isBool
+		}
+	}
+
+	var flags, rest []string
+	negative := false
+	for i := 2; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			rest = append(rest, args[i+1:]...)
+			i = len(args)
+		case negativeSizePattern.MatchString(arg):
+			negative = true
+			rest = append(rest, arg)
+		case strings.HasPrefix(arg, "-") && arg != "-":
+			flags = append(flags, arg)
+			name := strings.TrimLeft(arg, "-")
+			if !strings.Contains(name, "=") && takesValue[name] && i+1 < len(args) {
+				i++
+				flags = append(flags, args[i])
+			}
+		default:
+			rest = append(rest, arg)
+		}
+	}
+	if !negative {
+		return args
+	}
+
+	ret := append([]string{}, args[:2]...)
+	ret = append(ret, flags...)
+	ret = append(ret, "--")
+	return append(ret, rest...)
+}
+
+// splitList splits a comma separated flag value, ignoring empty elements.
+func splitList(s string) []string {
+	var ret []string
+	for _, e := range strings.Split(s, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			ret = append(ret, e)
+		}
+	}
+	return ret
+}
+
 func parseVariables(args []string) map[string]string {
 	m := map[string]string{}
 	for _, o := range args {
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+var profileExtensions = []string{".yml", ".yaml"}
+
+/*
+ * Profiles
+ */
+type Profile struct {
+	Name        string
+	Filename    string
+	Description string
+}
+
+// FindProfiles returns the cluster config templates stored in ProfileDir.
+func (s *App) FindProfiles() ([]*Profile, error) {
+	files, err := ioutil.ReadDir(s.ProfileDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var ret []*Profile
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		ext := path.Ext(f.Name())
+		if !isProfileExtension(ext) {
+			continue
+		}
+
+		filename := path.Join(s.ProfileDir, f.Name())
+		desc, err := readProfileDescription(filename)
+		if err != nil {
+			return nil, err
+		}
+
+		ret = append(ret, &Profile{
+			Name:        strings.TrimSuffix(f.Name(), ext),
+			Filename:    filename,
+			Description: desc,
+		})
+	}
+
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Name < ret[j].Name })
+	return ret, nil
+}
+
+// TemplateOptions select the cluster config template and the files of its variables,
+// given by the same flags to every command rendering a template.
+type TemplateOptions struct {
+	Filename string
+	Profile  string // name of the template in ProfileDir used instead of Filename
+	VarFiles []string
+}
+
+// ConfigFile returns the cluster config file for the profile.
+// If profile is empty, filename is returned as is.
+func (s *App) ConfigFile(filename string, profile string) (string, error) {
+	if profile == "" {
+		return filename, nil
+	}
+
+	for _, ext := range profileExtensions {
+		f := path.Join(s.ProfileDir, profile+ext)
+		if _, err := os.Stat(f); err == nil {
+			return f, nil
+		}
+	}
+	return "", &NotFoundError{Kind: "profile", Name: profile}
+}
+
+func (s *App) Profiles() error {
+	profiles, err := s.FindProfiles()
+	if err != nil {
+		return err
+	}
+
+	if len(profiles) == 0 {
+		fmt.Fprintf(s.Stderr, "no profiles found in %s\n", s.ProfileDir)
+		return nil
+	}
+
+	width := 0
+	for _, p := range profiles {
+		if len(p.Name) > width {
+			width = len(p.Name)
+		}
+	}
+	for _, p := range profiles {
+		fmt.Fprintf(s.Stdout, "%-*s  %s\n", width, p.Name, p.Description)
+	}
+	return nil
+}
+
+func isProfileExtension(ext string) bool {
+	for _, e := range profileExtensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+// readProfileDescription returns the first comment line of the template.
+func readProfileDescription(filename string) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line == "---" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			return strings.TrimSpace(strings.TrimLeft(line, "#")), nil
+		}
+		break
+	}
+	return "", sc.Err()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"testing"
+)
+
+/*
+ * Test Profiles
+ */
+func TestProfiles(t *testing.T) {
+	a := NewMockApp()
+	a.ProfileDir = "./testdata/profiles"
+
+	err := a.Profiles()
+	if err != nil {
+		t.Fatalf("Profiles command expected to success but failed with %s", err.Error())
+	}
+
+	exp := `dev          Small cluster for development
+spark-large  Large Spark ETL cluster
+`
+	if out := a.Stdout.String(); exp != out {
+		t.Errorf("'%s' expected but got '%s'", exp, out)
+	}
+}
+
+func TestStartWithProfile(t *testing.T) {
+	a := NewMockApp()
+	a.ProfileDir = "./testdata/profiles"
+
+	err := a.Start(&AppStartOptions{
+		Name: "test-cluster",
+		TemplateOptions: TemplateOptions{
+			Filename: "./cluster-sample.yml",
+			Profile:  "spark-large",
+		},
+	})
+	if err != nil {
+		t.Fatalf("Start command expected to success but failed with %s", err.Error())
+	}
+
+	ig := a.EMRAPI.LastRunJobFlowInput.Instances.InstanceGroups
+	if typ := aws.StringValue(ig[0].InstanceType); "r4.2xlarge" != typ {
+		t.Errorf("r4.2xlarge expected but %s", typ)
+	}
+}
+
+func TestStartWithUnknownProfile(t *testing.T) {
+	a := NewMockApp()
+	a.ProfileDir = "./testdata/profiles"
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test-cluster",
+		TemplateOptions: TemplateOptions{Profile: "unknown"},
+	})
+	if code := exitCode(err); ExitCodeNotFound != code {
+		t.Errorf("exit code %d expected but got %d (%v)", ExitCodeNotFound, code, err)
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"strings"
+	"time"
+)
+
+/*
+ * Reap idle or expired clusters
+ */
+
+// ExpiresAtTag is the tag set by `start --ttl`, holding the time in RFC3339 after which `reap` terminates the cluster.
+const ExpiresAtTag = "expires-at"
+
+// setExpiresAt tags the cluster config to expire after ttl.
+func setExpiresAt(config *emr.RunJobFlowInput, now time.Time, ttl time.Duration) {
+	value := now.Add(ttl).UTC().Format(time.RFC3339)
+	for _, t := range config.Tags {
+		if aws.StringValue(t.Key) == ExpiresAtTag {
+			t.Value = aws.String(value)
+			return
+		}
+	}
+	config.Tags = append(config.Tags, &emr.Tag{Key: aws.String(ExpiresAtTag), Value: aws.String(value)})
+}
+
+type AppReapOptions struct {
+	Idle      time.Duration // reap clusters idle longer than this, 0 to reap expired clusters only
+	KeepTags  []string      // KEY or KEY=VALUE of tags never reaped
+	Terminate bool          // terminate the clusters instead of reporting them
+}
+
+// Reap reports or terminates active clusters which are idle longer than Idle or past their expires-at tag.
+// Clusters whose state cannot be told (e.g. the master is unreachable) are left alone.
+func (s *App) Reap(o *AppReapOptions) error {
+	var ids []string
+	in := emr.ListClustersInput{ClusterStates: aws.StringSlice(ClusterStateActive)}
+	err := s.EMRAPI.ListClustersPages(&in, func(out *emr.ListClustersOutput, b bool) bool {
+		for _, c := range out.Clusters {
+			ids = append(ids, aws.StringValue(c.Id))
+		}
+		return true
+	})
+	if err != nil {
+		return apiError("ListClusters", err)
+	}
+
+	now := time.Now()
+	failed := 0
+	for _, id := range ids {
+		out, err := s.EMRAPI.DescribeCluster(&emr.DescribeClusterInput{ClusterId: aws.String(id)})
+		if err != nil {
+			return apiError("DescribeCluster", err)
+		}
+		c := out.Cluster
+		name := aws.StringValue(c.Name)
+
+		if tag := keptBy(c.Tags, o.KeepTags); tag != "" {
+			fmt.Fprintf(s.Stderr, "%s (%s): kept by tag %s\n", name, id, tag)
+			continue
+		}
+
+		reason, err := s.reapReason(c, now, o.Idle)
+		if err != nil {
+			fmt.Fprintf(s.Stderr, "%s (%s): skipped: %s\n", name, id, err.Error())
+			continue
+		}
+		if reason == "" {
+			continue
+		}
+		fmt.Fprintf(s.Stdout, "%s  %s  %s\n", name, id, reason)
+
+		if !o.Terminate {
+			continue
+		}
+		if aws.BoolValue(c.TerminationProtected) {
+			fmt.Fprintf(s.Stderr, "%s (%s): skipped: termination protection is enabled\n", name, id)
+			continue
+		}
+		err = s.Terminate(&AppTerminateOptions{Names: []string{id}, Yes: true})
+		if err != nil {
+			fmt.Fprintf(s.Stderr, "%s (%s): %s\n", name, id, err.Error())
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("failed to terminate %d clusters", failed)
+	}
+	return nil
+}
+
+// reapReason returns why the cluster should be reaped, or "" if it should not.
+func (s *App) reapReason(c *emr.Cluster, now time.Time, idle time.Duration) (string, error) {
+	for _, t := range c.Tags {
+		if aws.StringValue(t.Key) != ExpiresAtTag {
+			continue
+		}
+		expires, err := time.Parse(time.RFC3339, aws.StringValue(t.Value))
+		if err != nil {
+			return "", fmt.Errorf("invalid %s tag %s", ExpiresAtTag, aws.StringValue(t.Value))
+		}
+		if !now.Before(expires) {
+			return fmt.Sprintf("expired %s ago (%s %s)", formatAge(now.Sub(expires)), ExpiresAtTag, aws.StringValue(t.Value)), nil
+		}
+	}
+
+	if idle == 0 || c.Status == nil {
+		return "", nil
+	}
+	// clusters still starting are not idle
+	switch aws.StringValue(c.Status.State) {
+	case emr.ClusterStateRunning, emr.ClusterStateWaiting:
+	default:
+		return "", nil
+	}
+
+	master := aws.StringValue(c.MasterPublicDnsName)
+	if master == "" {
+		return "", fmt.Errorf("master is unknown")
+	}
+	var ready time.Time
+	if tl := c.Status.Timeline; tl != nil {
+		ready = aws.TimeValue(tl.ReadyDateTime)
+	}
+	activity, err := s.getClusterActivity(aws.StringValue(c.Id), master, ready)
+	if err != nil {
+		return "", err
+	}
+	if activity.RunningApps > 0 || activity.RunningSteps > 0 || activity.IdleSince.IsZero() {
+		return "", nil
+	}
+	if d := now.Sub(activity.IdleSince); d > idle {
+		return fmt.Sprintf("idle for %s", formatAge(d)), nil
+	}
+	return "", nil
+}
+
+// keptBy returns the first of the keep tags (KEY or KEY=VALUE) which the cluster has, or "".
+func keptBy(tags []*emr.Tag, keep []string) string {
+	for _, k := range keep {
+		kv := strings.SplitN(k, "=", 2)
+		for _, t := range tags {
+			if aws.StringValue(t.Key) != kv[0] {
+				continue
+			}
+			if len(kv) == 1 || aws.StringValue(t.Value) == kv[1] {
+				return k
+			}
+		}
+	}
+	return ""
+}
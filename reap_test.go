@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockReapClusters mocks clusters idle, busy, expired, kept and protected, and returns the expires-at of expired.
+func mockReapClusters(a *MockApp) string {
+	now := time.Now()
+	ready := now.Add(-3*time.Hour - 30*time.Second)
+	tag := func(k, v string) *emr.Tag { return &emr.Tag{Key: aws.String(k), Value: aws.String(v)} }
+	expired := now.Add(-time.Hour - 30*time.Second).UTC().Format(time.RFC3339)
+
+	var clusters []*emr.Cluster
+	for i, c := range []struct {
+		name      string
+		tags      []*emr.Tag
+		protected bool
+	}{
+		{"idle", nil, false},
+		{"busy", nil, false},
+		{"expired", []*emr.Tag{tag(ExpiresAtTag, expired)}, false},
+		{"kept", []*emr.Tag{tag(ExpiresAtTag, expired), tag("reap", "never")}, false},
+		{"protected", nil, true},
+	} {
+		clusters = append(clusters, &emr.Cluster{
+			Id:                   aws.String(fmt.Sprintf("j-%08d", i)),
+			Name:                 aws.String(c.name),
+			MasterPublicDnsName:  aws.String(c.name),
+			Tags:                 c.tags,
+			TerminationProtected: aws.Bool(c.protected),
+			Status: &emr.ClusterStatus{
+				State:    aws.String(emr.ClusterStateWaiting),
+				Timeline: &emr.ClusterTimeline{ReadyDateTime: aws.Time(ready)},
+			},
+		})
+	}
+
+	mockClusters(a, clusters...)
+	a.OpHandler.MockGet = func(url string) ([]byte, error) {
+		if strings.HasPrefix(url, "http://busy:") {
+			return []byte(`{"apps": {"app": [{"name": "etl-daily", "state": "RUNNING"}]}}`), nil
+		}
+		return []byte(`{"apps": null}`), nil
+	}
+	return expired
+}
+
+/*
+ * Test Reap
+ */
+func TestReap(t *testing.T) {
+	a := NewMockApp()
+	expired := mockReapClusters(a)
+
+	err := a.Reap(&AppReapOptions{Idle: 2 * time.Hour, KeepTags: []string{"reap=never"}})
+	if err != nil {
+		t.Fatalf("Reap command expected to success but failed with %s", err.Error())
+	}
+	if a.EMRAPI.LastTerminateJobFlowsInput != nil {
+		t.Errorf("TerminateJobFlows API is expected not to be called without --terminate")
+	}
+
+	exp := "idle  j-00000000  idle for 3h0m\n" +
+		"expired  j-00000002  expired 1h0m ago (expires-at " +
+		expired + ")\n" +
+		"protected  j-00000004  idle for 3h0m\n"
+	if out := a.Stdout.String(); exp != out {
+		t.Errorf("'%s' expected but got '%s'", exp, out)
+	}
+	if msg := a.Stderr.String(); !strings.Contains(msg, "kept (j-00000003): kept by tag reap=never") {
+		t.Errorf("kept cluster expected in '%s'", msg)
+	}
+}
+
+func TestReapTerminate(t *testing.T) {
+	a := NewMockApp()
+	mockReapClusters(a)
+	var ids []string
+	a.EMRAPI.MockTerminateJobFlows = func(input *emr.TerminateJobFlowsInput) (*emr.TerminateJobFlowsOutput, error) {
+		ids = append(ids, aws.StringValueSlice(input.JobFlowIds)...)
+		return &emr.TerminateJobFlowsOutput{}, nil
+	}
+
+	err := a.Reap(&AppReapOptions{Idle: 4 * time.Hour, KeepTags: []string{"reap"}, Terminate: true})
+	if err != nil {
+		t.Fatalf("Reap command expected to success but failed with %s", err.Error())
+	}
+	if exp := []string{"j-00000002"}; !reflect.DeepEqual(exp, ids) {
+		t.Errorf("%v expected but got %v", exp, ids)
+	}
+}
+
+func TestReapProtected(t *testing.T) {
+	a := NewMockApp()
+	mockReapClusters(a)
+	var ids []string
+	a.EMRAPI.MockTerminateJobFlows = func(input *emr.TerminateJobFlowsInput) (*emr.TerminateJobFlowsOutput, error) {
+		ids = append(ids, aws.StringValueSlice(input.JobFlowIds)...)
+		return &emr.TerminateJobFlowsOutput{}, nil
+	}
+
+	err := a.Reap(&AppReapOptions{Idle: 2 * time.Hour, KeepTags: []string{"reap"}, Terminate: true})
+	if err != nil {
+		t.Fatalf("Reap command expected to success but failed with %s", err.Error())
+	}
+	if exp := []string{"j-00000000", "j-00000002"}; !reflect.DeepEqual(exp, ids) {
+		t.Errorf("%v expected but got %v", exp, ids)
+	}
+	if msg := a.Stderr.String(); !strings.Contains(msg, "protected (j-00000004): skipped: termination protection is enabled") {
+		t.Errorf("protected cluster expected to be skipped in '%s'", msg)
+	}
+}
+
+func TestStartTTL(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test-cluster",
+		TemplateOptions: TemplateOptions{Filename: "./cluster-sample.yml"},
+		TTL:             8 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("Start command expected to success but failed with %s", err.Error())
+	}
+
+	var expires string
+	for _, tag := range a.EMRAPI.LastRunJobFlowInput.Tags {
+		if aws.StringValue(tag.Key) == ExpiresAtTag {
+			expires = aws.StringValue(tag.Value)
+		}
+	}
+	at, err := time.Parse(time.RFC3339, expires)
+	if err != nil {
+		t.Fatalf("expires-at tag expected in RFC3339 but got '%s'", expires)
+	}
+	if d := time.Until(at); d < 7*time.Hour || d > 8*time.Hour {
+		t.Errorf("expires-at about 8 hours later expected but got %s", expires)
+	}
+}
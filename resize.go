@@ -0,0 +1,334 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * Resize size specs
+ *
+ *   3    resize to 3
+ *   +4   add 4 instances to the current size
+ *   -2   remove 2 instances from the current size
+ *   x2   multiply the current size by 2
+ *   50%  resize to 50% of the current size
+ */
+
+// ResizeSpec is a size given to resize, which may be relative to the current size.
+type ResizeSpec struct {
+	Op    string // "", "+", "-", "x" or "%"
+	Value float64
+}
+
+func parseResizeSpec(s string) (*ResizeSpec, error) {
+	spec := &ResizeSpec{}
+	v := strings.TrimSpace(s)
+	switch {
+	case strings.HasPrefix(v, "+"), strings.HasPrefix(v, "-"), strings.HasPrefix(v, "x"):
+		spec.Op, v = v[:1], v[1:]
+	case strings.HasSuffix(v, "%"):
+		spec.Op, v = "%", v[:len(v)-1]
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil || f < 0 || (spec.Op != "x" && spec.Op != "%" && f != math.Trunc(f)) {
+		return nil, fmt.Errorf("invalid size %s (N, +N, -N, xN or N%% expected)", s)
+	}
+	spec.Value = f
+	return spec, nil
+}
+
+// IsRelative returns true if the size depends on the current size.
+func (r *ResizeSpec) IsRelative() bool {
+	return r.Op != ""
+}
+
+// Size returns the new size computed from the current size. Fractions are rounded.
+func (r *ResizeSpec) Size(current int64) int64 {
+	var size float64
+	switch r.Op {
+	case "+":
+		size = float64(current) + r.Value
+	case "-":
+		size = float64(current) - r.Value
+	case "x":
+		size = float64(current) * r.Value
+	case "%":
+		size = float64(current) * r.Value / 100
+	default:
+		size = r.Value
+	}
+	if size < 0 {
+		return 0
+	}
+	return int64(math.Floor(size + 0.5))
+}
+
+// resizeLimits returns the clamps of the instance group.
+// The flags win over the constraints of the autoscaling policy in the template. 0 means no limit.
+func resizeLimits(config *emr.InstanceGroupConfig, min int, max int) (int64, int64) {
+	lo, hi := int64(min), int64(max)
+	if p := config.AutoScalingPolicy; p != nil && p.Constraints != nil {
+		if lo == 0 {
+			lo = aws.Int64Value(p.Constraints.MinCapacity)
+		}
+		if hi == 0 {
+			hi = aws.Int64Value(p.Constraints.MaxCapacity)
+		}
+	}
+	return lo, hi
+}
+
+func clampSize(size int64, min int64, max int64) int64 {
+	if max > 0 && size > max {
+		size = max
+	}
+	if size < min {
+		size = min
+	}
+	return size
+}
+
+/*
+ * Wait for resize
+ */
+
+// ResizePollInterval is how often the instance group is checked while waiting for a resize.
+var ResizePollInterval = 15 * time.Second
+
+// WaitInstanceGroup polls the instance group until size instances are requested and running.
+// Right after a resize the group may still report the old size as running, which is not waited for.
+// Progress and state change reasons (e.g. insufficient spot capacity) are printed to Stderr.
+func (s *App) WaitInstanceGroup(clusterId string, instanceGroupId string, size int64, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var progress, reason string
+	for {
+		ig, err := s.findInstanceGroupById(clusterId, instanceGroupId)
+		if err != nil {
+			return err
+		}
+
+		name := aws.StringValue(ig.Name)
+		state := ""
+		if ig.Status != nil {
+			state = aws.StringValue(ig.Status.State)
+		}
+		running := aws.Int64Value(ig.RunningInstanceCount)
+		requested := aws.Int64Value(ig.RequestedInstanceCount)
+
+		if p := fmt.Sprintf("%s: %d/%d running (%s)", name, running, size, state); p != progress {
+			fmt.Fprintln(s.Stderr, p)
+			progress = p
+		}
+		if r := stateChangeReason(ig); r != "" && r != reason {
+			fmt.Fprintf(s.Stderr, "%s: %s\n", name, r)
+			reason = r
+		}
+
+		switch state {
+		case emr.InstanceGroupStateRunning:
+			if requested == size && running == size {
+				fmt.Fprintf(s.Stderr, "%s is resized to %d\n", name, size)
+				return nil
+			}
+		case emr.InstanceGroupStateArrested, emr.InstanceGroupStateSuspended,
+			emr.InstanceGroupStateTerminated, emr.InstanceGroupStateEnded:
+			return fmt.Errorf("instance group %s is %s: %s", name, state, reason)
+		}
+
+		if timeout > 0 && time.Now().After(deadline) {
+			return &TimeoutError{Target: fmt.Sprintf("instance group %s (%d/%d running)", name, running, size), Timeout: timeout}
+		}
+		time.Sleep(ResizePollInterval)
+	}
+}
+
+func (s *App) findInstanceGroupById(clusterId string, instanceGroupId string) (*emr.InstanceGroup, error) {
+	in := emr.ListInstanceGroupsInput{ClusterId: aws.String(clusterId)}
+
+	var ret *emr.InstanceGroup
+	err := s.EMRAPI.ListInstanceGroupsPages(&in, func(out *emr.ListInstanceGroupsOutput, b bool) bool {
+		for _, ig := range out.InstanceGroups {
+			if aws.StringValue(ig.Id) == instanceGroupId {
+				ret = ig
+				return false
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return nil, apiError("ListInstanceGroups", err)
+	}
+	if ret == nil {
+		return nil, &NotFoundError{Kind: "instance group", Name: instanceGroupId}
+	}
+	return ret, nil
+}
+
+func stateChangeReason(ig *emr.InstanceGroup) string {
+	if ig.Status == nil || ig.Status.StateChangeReason == nil {
+		return ""
+	}
+	return aws.StringValue(ig.Status.StateChangeReason.Message)
+}
+
+/*
+ * Graceful shrink
+ */
+
+// YarnNode is a node reported by the YARN ResourceManager nodes API.
+type YarnNode struct {
+	NodeHostName  string `json:"nodeHostName"`
+	State         string `json:"state"`
+	NumContainers int    `json:"numContainers"`
+}
+
+type yarnNodesBuffer struct {
+	Nodes struct {
+		Node []*YarnNode `json:"node"`
+	} `json:"nodes"`
+}
+
+type yarnAppsBuffer struct {
+	Apps struct {
+		App []struct {
+			Name              string `json:"name"`
+			AmHostHttpAddress string `json:"amHostHttpAddress"`
+		} `json:"app"`
+	} `json:"apps"`
+}
+
+// gracefulShrinkPolicy builds ShrinkPolicy for shrinking the instance group to size.
+// Nodes running ApplicationMasters are protected, and the nodes with the fewest containers are terminated.
+// YARN decommissions them within the timeout, so that running containers can finish.
+func (s *App) gracefulShrinkPolicy(clusterId string, ig *emr.InstanceGroup, size int64, timeout time.Duration) (*emr.ShrinkPolicy, error) {
+	master, err := s.GetMaster(clusterId)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := s.getYarnContainers(master)
+	if err != nil {
+		return nil, err
+	}
+
+	amHosts, err := s.getApplicationMasterHosts(master)
+	if err != nil {
+		return nil, err
+	}
+
+	instances, err := s.listRunningInstances(clusterId, aws.StringValue(ig.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	var protect, candidates []*emr.Instance
+	fmt.Fprintf(s.Stderr, "%s nodes:\n", aws.StringValue(ig.Name))
+	for _, in := range instances {
+		host := aws.StringValue(in.PrivateDnsName)
+		note := ""
+		if amHosts[host] {
+			protect = append(protect, in)
+			note = "  ApplicationMaster (protected)"
+		} else {
+			candidates = append(candidates, in)
+		}
+		fmt.Fprintf(s.Stderr, "  %s  %s  %d containers%s\n", aws.StringValue(in.Ec2InstanceId), host, containers[host], note)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return containers[aws.StringValue(candidates[i].PrivateDnsName)] < containers[aws.StringValue(candidates[j].PrivateDnsName)]
+	})
+	n := int(int64(len(instances)) - size)
+	if n > len(candidates) {
+		n = len(candidates)
+	}
+	if n < 0 {
+		n = 0
+	}
+
+	policy := &emr.InstanceResizePolicy{
+		InstanceTerminationTimeout: aws.Int64(int64(timeout.Seconds())),
+	}
+	for _, in := range protect {
+		policy.InstancesToProtect = append(policy.InstancesToProtect, in.Ec2InstanceId)
+	}
+	for _, in := range candidates[:n] {
+		policy.InstancesToTerminate = append(policy.InstancesToTerminate, in.Ec2InstanceId)
+	}
+
+	return &emr.ShrinkPolicy{
+		DecommissionTimeout:  aws.Int64(int64(timeout.Seconds())),
+		InstanceResizePolicy: policy,
+	}, nil
+}
+
+// getYarnContainers returns the number of running containers per node host name.
+func (s *App) getYarnContainers(master string) (map[string]int, error) {
+	url := fmt.Sprintf("http://%s:8088/ws/v1/cluster/nodes", master)
+	buf, err := s.OpHandler.HttpGet(url)
+	if err != nil {
+		return nil, &UnreachableError{URL: url, Err: err}
+	}
+
+	dat := yarnNodesBuffer{}
+	err = json.Unmarshal(buf, &dat)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string]int{}
+	for _, n := range dat.Nodes.Node {
+		ret[n.NodeHostName] = n.NumContainers
+	}
+	return ret, nil
+}
+
+// getApplicationMasterHosts returns the host names running ApplicationMasters of running applications.
+func (s *App) getApplicationMasterHosts(master string) (map[string]bool, error) {
+	url := fmt.Sprintf("http://%s:8088/ws/v1/cluster/apps?states=RUNNING", master)
+	buf, err := s.OpHandler.HttpGet(url)
+	if err != nil {
+		return nil, &UnreachableError{URL: url, Err: err}
+	}
+
+	dat := yarnAppsBuffer{}
+	err = json.Unmarshal(buf, &dat)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := map[string]bool{}
+	for _, app := range dat.Apps.App {
+		if host := strings.SplitN(app.AmHostHttpAddress, ":", 2)[0]; host != "" {
+			ret[host] = true
+		}
+	}
+	return ret, nil
+}
+
+func (s *App) listRunningInstances(clusterId string, instanceGroupId string) ([]*emr.Instance, error) {
+	in := emr.ListInstancesInput{
+		ClusterId:       aws.String(clusterId),
+		InstanceGroupId: aws.String(instanceGroupId),
+		InstanceStates:  aws.StringSlice([]string{emr.InstanceStateRunning}),
+	}
+
+	var ret []*emr.Instance
+	err := s.EMRAPI.ListInstancesPages(&in, func(out *emr.ListInstancesOutput, b bool) bool {
+		ret = append(ret, out.Instances...)
+		return true
+	})
+	if err != nil {
+		return nil, apiError("ListInstances", err)
+	}
+	return ret, nil
+}
@@ -0,0 +1,306 @@
+package main
+
+import (
+	"errors"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResizeSpec(t *testing.T) {
+	cases := []struct {
+		spec string
+		exp  int64
+	}{
+		{"3", 3},
+		{"+4", 9},
+		{"-2", 3},
+		{"-8", 0},
+		{"x2", 10},
+		{"x1.5", 8},
+		{"50%", 3},
+		{"200%", 10},
+	}
+	for _, c := range cases {
+		spec, err := parseResizeSpec(c.spec)
+		if err != nil {
+			t.Errorf("%s expected to be parsed but failed with %s", c.spec, err.Error())
+			continue
+		}
+		if size := spec.Size(5); c.exp != size {
+			t.Errorf("%s of 5 expected to be %d but got %d", c.spec, c.exp, size)
+		}
+	}
+
+	for _, s := range []string{"", "abc", "+", "1.5", "+1.5", "x-2", "--2"} {
+		if _, err := parseResizeSpec(s); err == nil {
+			t.Errorf("%q expected to be invalid", s)
+		}
+	}
+}
+
+func TestEscapeNegativeSize(t *testing.T) {
+	app := BuildCLI(&NewMockApp().App)
+	cases := []struct {
+		args string
+		exp  string
+	}{
+		{"emrcmd resize foo task -2", "emrcmd resize -- foo task -2"},
+		{"emrcmd resize foo task -2 --wait --timeout 10m a=b", "emrcmd resize --wait --timeout 10m -- foo task -2 a=b"},
+		{"emrcmd resize -f c.yml --min=1 foo task -2", "emrcmd resize -f c.yml --min=1 -- foo task -2"},
+		{"emrcmd resize foo task -- -2", "emrcmd resize foo task -- -2"},
+		{"emrcmd resize foo task +2 --wait", "emrcmd resize foo task +2 --wait"},
+		{"emrcmd scaling set foo -2", "emrcmd scaling set foo -2"},
+	}
+	for _, c := range cases {
+		if got := strings.Join(escapeNegativeSize(app, strings.Fields(c.args)), " "); c.exp != got {
+			t.Errorf("%q expected but got %q", c.exp, got)
+		}
+	}
+}
+
+func TestResizeRelative(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Resize(&AppResizeOptions{
+		Name:              "test",
+		InstanceGroupName: "core",
+		Size:              "+3",
+		TemplateOptions:   TemplateOptions{Filename: "./cluster-sample.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Resize command expected to success but failed with %s", err.Error())
+	}
+
+	n := aws.Int64Value(a.EMRAPI.LastModifyInstanceGroupsInput.InstanceGroups[0].InstanceCount)
+	if 8 != n {
+		t.Errorf("8 expected but got %d", n)
+	}
+	if msg := a.Stderr.String(); !strings.Contains(msg, "resizing core from 5 to 8...") {
+		t.Errorf("resizing message expected but got '%s'", msg)
+	}
+}
+
+func TestResizeRelativeClamped(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Resize(&AppResizeOptions{
+		Name:              "test",
+		InstanceGroupName: "core",
+		Size:              "x2",
+		Max:               6,
+		TemplateOptions:   TemplateOptions{Filename: "./cluster-sample.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Resize command expected to success but failed with %s", err.Error())
+	}
+
+	n := aws.Int64Value(a.EMRAPI.LastModifyInstanceGroupsInput.InstanceGroups[0].InstanceCount)
+	if 6 != n {
+		t.Errorf("6 expected but got %d", n)
+	}
+}
+
+func TestResizeRelativeNew(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Resize(&AppResizeOptions{
+		Name:              "test",
+		InstanceGroupName: "task",
+		Size:              "+2",
+		TemplateOptions:   TemplateOptions{Filename: "./cluster-sample.yml"},
+		DryRun:            true,
+	})
+	if err != nil {
+		t.Fatalf("Resize command expected to success but failed with %s", err.Error())
+	}
+
+	if out := a.Stdout.String(); !strings.Contains(out, "instancecount: 2\n") {
+		t.Errorf("instancecount: 2 expected but got '%s'", out)
+	}
+	if a.EMRAPI.LastAddInstanceGroupsInput != nil {
+		t.Errorf("AddInstanceGroups API is expected not to be called in dry-run")
+	}
+}
+
+// mockResizingGroup returns the core group resizing from 2 to 4, which is running after the given polls.
+func mockResizingGroup(a *MockApp, polls int, reason string) *int {
+	n := 0
+	a.EMRAPI.MockListInstanceGroupsPages = func(input *emr.ListInstanceGroupsInput, fn func(*emr.ListInstanceGroupsOutput, bool) bool) error {
+		n += 1
+		ig := &emr.InstanceGroup{
+			Id:                     aws.String("ig-00000002"),
+			Name:                   aws.String("core"),
+			InstanceGroupType:      aws.String(emr.InstanceGroupTypeCore),
+			RequestedInstanceCount: aws.Int64(4),
+			RunningInstanceCount:   aws.Int64(2),
+			Status: &emr.InstanceGroupStatus{
+				State:             aws.String(emr.InstanceGroupStateResizing),
+				StateChangeReason: &emr.InstanceGroupStateChangeReason{Message: aws.String(reason)},
+			},
+		}
+		if n > polls {
+			ig.RunningInstanceCount = aws.Int64(4)
+			ig.Status.State = aws.String(emr.InstanceGroupStateRunning)
+			ig.Status.StateChangeReason = nil
+		}
+		fn(&emr.ListInstanceGroupsOutput{InstanceGroups: []*emr.InstanceGroup{ig}}, true)
+		return nil
+	}
+	return &n
+}
+
+func TestResizeWait(t *testing.T) {
+	ResizePollInterval = time.Millisecond
+	a := NewMockApp()
+	n := mockResizingGroup(a, 3, "Unable to provision spot instances due to insufficient capacity")
+
+	err := a.WaitInstanceGroup("j-00000000", "ig-00000002", 4, time.Minute)
+	if err != nil {
+		t.Fatalf("WaitInstanceGroup expected to success but failed with %s", err.Error())
+	}
+
+	if 4 != *n {
+		t.Errorf("4 polls expected but got %d", *n)
+	}
+	exp := strings.Join([]string{
+		"core: 2/4 running (RESIZING)",
+		"core: Unable to provision spot instances due to insufficient capacity",
+		"core: 4/4 running (RUNNING)",
+		"core is resized to 4",
+		"",
+	}, "\n")
+	if msg := a.Stderr.String(); exp != msg {
+		t.Errorf("'%s' expected but got '%s'", exp, msg)
+	}
+}
+
+func TestResizeWaitStaleGroup(t *testing.T) {
+	ResizePollInterval = time.Millisecond
+	a := NewMockApp()
+	n := 0
+	// the group reports the old size as running until the resize is registered
+	a.EMRAPI.MockListInstanceGroupsPages = func(input *emr.ListInstanceGroupsInput, fn func(*emr.ListInstanceGroupsOutput, bool) bool) error {
+		n += 1
+		requested, running := int64(2), int64(2)
+		if n > 2 {
+			requested, running = 4, 4
+		}
+		fn(&emr.ListInstanceGroupsOutput{InstanceGroups: []*emr.InstanceGroup{{
+			Id:                     aws.String("ig-00000002"),
+			Name:                   aws.String("core"),
+			RequestedInstanceCount: aws.Int64(requested),
+			RunningInstanceCount:   aws.Int64(running),
+			Status:                 &emr.InstanceGroupStatus{State: aws.String(emr.InstanceGroupStateRunning)},
+		}}}, true)
+		return nil
+	}
+
+	err := a.WaitInstanceGroup("j-00000000", "ig-00000002", 4, time.Minute)
+	if err != nil {
+		t.Fatalf("WaitInstanceGroup expected to success but failed with %s", err.Error())
+	}
+	if 3 != n {
+		t.Errorf("3 polls expected but got %d", n)
+	}
+}
+
+func TestResizeWaitTimeout(t *testing.T) {
+	ResizePollInterval = time.Millisecond
+	a := NewMockApp()
+	mockResizingGroup(a, 1000, "")
+
+	err := a.WaitInstanceGroup("j-00000000", "ig-00000002", 4, 5*time.Millisecond)
+	if _, ok := err.(*TimeoutError); !ok {
+		t.Fatalf("TimeoutError expected but got %v", err)
+	}
+	if code := exitCode(err); ExitCodeTimeout != code {
+		t.Errorf("exit code %d expected but got %d", ExitCodeTimeout, code)
+	}
+}
+
+func TestResizeGraceful(t *testing.T) {
+	ResizePollInterval = time.Millisecond
+	a := NewMockApp()
+	a.OpHandler.MockGet = func(url string) ([]byte, error) {
+		switch url {
+		case "http://master-public-dns-name:8088/ws/v1/cluster/nodes":
+			return []byte(`{"nodes": {"node": [
+				{"nodeHostName": "node-1", "state": "RUNNING", "numContainers": 4},
+				{"nodeHostName": "node-2", "state": "RUNNING", "numContainers": 0},
+				{"nodeHostName": "node-3", "state": "RUNNING", "numContainers": 1},
+				{"nodeHostName": "node-4", "state": "RUNNING", "numContainers": 2}
+			]}}`), nil
+		case "http://master-public-dns-name:8088/ws/v1/cluster/apps?states=RUNNING":
+			return []byte(`{"apps": {"app": [{"amHostHttpAddress": "node-3:8042"}]}}`), nil
+		}
+		return nil, errors.New("unexpected url " + url)
+	}
+	a.EMRAPI.MockListInstancesPages = func(input *emr.ListInstancesInput, fn func(*emr.ListInstancesOutput, bool) bool) error {
+		var instances []*emr.Instance
+		for _, i := range []string{"1", "2", "3", "4"} {
+			instances = append(instances, &emr.Instance{
+				Ec2InstanceId:  aws.String("i-" + i),
+				PrivateDnsName: aws.String("node-" + i),
+			})
+		}
+		fn(&emr.ListInstancesOutput{Instances: instances}, true)
+		return nil
+	}
+	a.EMRAPI.MockListInstanceGroupsPages = func(input *emr.ListInstanceGroupsInput, fn func(*emr.ListInstanceGroupsOutput, bool) bool) error {
+		running := int64(4)
+		if a.EMRAPI.LastModifyInstanceGroupsInput != nil {
+			running = 2
+		}
+		fn(&emr.ListInstanceGroupsOutput{
+			InstanceGroups: []*emr.InstanceGroup{
+				{
+					Id:                     aws.String("ig-00000003"),
+					Name:                   aws.String("task"),
+					InstanceGroupType:      aws.String(emr.InstanceGroupTypeTask),
+					RequestedInstanceCount: aws.Int64(running),
+					RunningInstanceCount:   aws.Int64(running),
+					Status:                 &emr.InstanceGroupStatus{State: aws.String(emr.InstanceGroupStateRunning)},
+				},
+			},
+		}, true)
+		return nil
+	}
+
+	err := a.Resize(&AppResizeOptions{
+		Name:                "test",
+		InstanceGroupName:   "task",
+		Size:                "-2",
+		TemplateOptions:     TemplateOptions{Filename: "./cluster-sample.yml"},
+		Graceful:            true,
+		DecommissionTimeout: 10 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("Resize command expected to success but failed with %s", err.Error())
+	}
+
+	exp := &emr.ShrinkPolicy{
+		DecommissionTimeout: aws.Int64(600),
+		InstanceResizePolicy: &emr.InstanceResizePolicy{
+			InstanceTerminationTimeout: aws.Int64(600),
+			InstancesToProtect:         aws.StringSlice([]string{"i-3"}),
+			InstancesToTerminate:       aws.StringSlice([]string{"i-2", "i-4"}),
+		},
+	}
+	mod := a.EMRAPI.LastModifyInstanceGroupsInput.InstanceGroups[0]
+	if !reflect.DeepEqual(exp, mod.ShrinkPolicy) {
+		t.Errorf("%s expected but got %s", exp, mod.ShrinkPolicy)
+	}
+	if n := aws.Int64Value(mod.InstanceCount); 2 != n {
+		t.Errorf("2 expected but got %d", n)
+	}
+	if msg := a.Stderr.String(); !strings.Contains(msg, "i-3  node-3  1 containers  ApplicationMaster (protected)") {
+		t.Errorf("node containers expected but got '%s'", msg)
+	}
+	if msg := a.Stderr.String(); !strings.Contains(msg, "task is resized to 2") {
+		t.Errorf("resize is expected to be waited but got '%s'", msg)
+	}
+}
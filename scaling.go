@@ -0,0 +1,262 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"sort"
+	"strings"
+)
+
+/*
+ * Scaling policies
+ *
+ * The `scaling` section of a template:
+ *
+ *   scaling:
+ *     managed:
+ *       unittype: Instances
+ *       minimumcapacityunits: 2
+ *       maximumcapacityunits: 20
+ *       maximumondemandcapacityunits: 10
+ *       maximumcorecapacityunits: 4
+ *     autoscaling:
+ *       task:
+ *         constraints: {mincapacity: 0, maxcapacity: 10}
+ *         rules: [...]
+ */
+
+// ScalingConfig is the managed scaling policy of the cluster and the auto-scaling policies of its instance groups.
+type ScalingConfig struct {
+	Managed     *emr.ComputeLimits                `yaml:"managed"`
+	AutoScaling map[string]*emr.AutoScalingPolicy `yaml:"autoscaling"`
+}
+
+func validateScalingConfig(scaling *ScalingConfig, config *emr.RunJobFlowInput, dat []byte) []*ConfigError {
+	if scaling == nil {
+		return nil
+	}
+	var errs []*ConfigError
+	lines := strings.Split(string(dat), "\n")
+
+	if scaling.Managed != nil {
+		errs = append(errs, paramErrors("scaling.managed", scaling.Managed.Validate())...)
+		// EMR rejects auto-scaling policies on clusters with managed scaling
+		if len(scaling.AutoScaling) > 0 {
+			errs = append(errs, newConfigError(lines, keyPattern("autoscaling"), 1,
+				"scaling: managed and autoscaling cannot be used together"))
+		}
+	}
+
+	roles := map[string]string{}
+	if config.Instances != nil {
+		for _, ig := range config.Instances.InstanceGroups {
+			roles[aws.StringValue(ig.Name)] = aws.StringValue(ig.InstanceRole)
+		}
+	}
+	for _, name := range sortedPolicyNames(scaling.AutoScaling) {
+		role, ok := roles[name]
+		if !ok {
+			errs = append(errs, newConfigError(lines, keyPattern(name), 1,
+				fmt.Sprintf("scaling.autoscaling: instance group %s is not defined", name)))
+			continue
+		}
+		if role == emr.InstanceRoleTypeMaster {
+			errs = append(errs, newConfigError(lines, keyPattern(name), 1,
+				fmt.Sprintf("scaling.autoscaling: instance group %s is the master, which cannot be scaled", name)))
+			continue
+		}
+		if p := scaling.AutoScaling[name]; p == nil {
+			errs = append(errs, &ConfigError{Message: fmt.Sprintf("scaling.autoscaling.%s: policy is empty", name)})
+		} else {
+			errs = append(errs, paramErrors("scaling.autoscaling."+name, p.Validate())...)
+		}
+	}
+	return errs
+}
+
+func sortedPolicyNames(policies map[string]*emr.AutoScalingPolicy) []string {
+	var names []string
+	for name := range policies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setScaling puts the policies of the scaling section into the RunJobFlow request.
+// Groups with auto-scaling policies start with at least their minimum capacity.
+func setScaling(config *emr.RunJobFlowInput, scaling *ScalingConfig) {
+	if scaling.Managed != nil {
+		config.ManagedScalingPolicy = &emr.ManagedScalingPolicy{ComputeLimits: scaling.Managed}
+	}
+	if config.Instances == nil {
+		return
+	}
+	for _, ig := range config.Instances.InstanceGroups {
+		p := scaling.AutoScaling[aws.StringValue(ig.Name)]
+		if p == nil {
+			continue
+		}
+		ig.AutoScalingPolicy = p
+		if p.Constraints != nil && aws.Int64Value(ig.InstanceCount) < aws.Int64Value(p.Constraints.MinCapacity) {
+			ig.InstanceCount = p.Constraints.MinCapacity
+		}
+	}
+}
+
+// applyScaling puts the policies of the scaling section to the running cluster.
+func (s *App) applyScaling(id string, scaling *ScalingConfig) error {
+	if scaling.Managed != nil {
+		in := emr.PutManagedScalingPolicyInput{
+			ClusterId:            aws.String(id),
+			ManagedScalingPolicy: &emr.ManagedScalingPolicy{ComputeLimits: scaling.Managed},
+		}
+		_, err := s.EMRAPI.PutManagedScalingPolicy(&in)
+		if err != nil {
+			return apiError("PutManagedScalingPolicy", err)
+		}
+		fmt.Fprintf(s.Stderr, "managed scaling: %d to %d %s\n",
+			aws.Int64Value(scaling.Managed.MinimumCapacityUnits),
+			aws.Int64Value(scaling.Managed.MaximumCapacityUnits),
+			strings.ToLower(aws.StringValue(scaling.Managed.UnitType)))
+	}
+
+	for _, name := range sortedPolicyNames(scaling.AutoScaling) {
+		ig, err := s.FindInstanceGroupByName(id, name)
+		if err != nil {
+			return err
+		}
+		if ig == nil {
+			return &NotFoundError{Kind: "instance group", Name: name}
+		}
+
+		in := emr.PutAutoScalingPolicyInput{
+			ClusterId:         aws.String(id),
+			InstanceGroupId:   ig.Id,
+			AutoScalingPolicy: scaling.AutoScaling[name],
+		}
+		_, err = s.EMRAPI.PutAutoScalingPolicy(&in)
+		if err != nil {
+			return apiError("PutAutoScalingPolicy", err)
+		}
+		fmt.Fprintf(s.Stderr, "auto-scaling policy: %s\n", name)
+	}
+	return nil
+}
+
+/*
+ * SCALING command
+ */
+type AppScalingOptions struct {
+	Name string
+	Vars map[string]string
+	TemplateOptions
+	Output string
+}
+
+// ScalingGet prints the policies of the cluster in the format of the scaling section.
+func (s *App) ScalingGet(o *AppScalingOptions) error {
+	id, err := s.FindClusterId(o.Name)
+	if err != nil {
+		return err
+	}
+
+	scaling := ScalingConfig{AutoScaling: map[string]*emr.AutoScalingPolicy{}}
+
+	out, err := s.EMRAPI.GetManagedScalingPolicy(&emr.GetManagedScalingPolicyInput{ClusterId: aws.String(id)})
+	if err != nil {
+		return apiError("GetManagedScalingPolicy", err)
+	}
+	if out.ManagedScalingPolicy != nil {
+		scaling.Managed = out.ManagedScalingPolicy.ComputeLimits
+	}
+
+	in := emr.ListInstanceGroupsInput{ClusterId: aws.String(id)}
+	err = s.EMRAPI.ListInstanceGroupsPages(&in, func(out *emr.ListInstanceGroupsOutput, b bool) bool {
+		for _, ig := range out.InstanceGroups {
+			if p := ig.AutoScalingPolicy; p != nil {
+				scaling.AutoScaling[aws.StringValue(ig.Name)] = &emr.AutoScalingPolicy{
+					Constraints: p.Constraints,
+					Rules:       p.Rules,
+				}
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return apiError("ListInstanceGroups", err)
+	}
+
+	return writeConfig(s.Stdout, map[string]interface{}{"scaling": scaling}, o.Output, nil)
+}
+
+// ScalingSet puts the policies in the scaling section of the template to the cluster.
+func (s *App) ScalingSet(o *AppScalingOptions) error {
+	filename, err := s.ConfigFile(o.Filename, o.Profile)
+	if err != nil {
+		return err
+	}
+
+	loader, err := s.newConfigLoader(o.Name, o.Vars, o.VarFiles)
+	if err != nil {
+		return err
+	}
+
+	t, err := loader.LoadTemplate(filename)
+	if err != nil {
+		return err
+	}
+	if t.Scaling == nil {
+		return fmt.Errorf("%s has no scaling section", filename)
+	}
+
+	id, err := s.FindClusterId(o.Name)
+	if err != nil {
+		return err
+	}
+
+	return s.applyScaling(id, t.Scaling)
+}
+
+// ScalingRemove removes the managed scaling policy and the auto-scaling policies of all the instance groups.
+func (s *App) ScalingRemove(o *AppScalingOptions) error {
+	id, err := s.FindClusterId(o.Name)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.EMRAPI.RemoveManagedScalingPolicy(&emr.RemoveManagedScalingPolicyInput{ClusterId: aws.String(id)})
+	if err != nil {
+		return apiError("RemoveManagedScalingPolicy", err)
+	}
+
+	var igs []*emr.InstanceGroup
+	in := emr.ListInstanceGroupsInput{ClusterId: aws.String(id)}
+	err = s.EMRAPI.ListInstanceGroupsPages(&in, func(out *emr.ListInstanceGroupsOutput, b bool) bool {
+		for _, ig := range out.InstanceGroups {
+			if ig.AutoScalingPolicy != nil {
+				igs = append(igs, ig)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return apiError("ListInstanceGroups", err)
+	}
+
+	for _, ig := range igs {
+		in := emr.RemoveAutoScalingPolicyInput{
+			ClusterId:       aws.String(id),
+			InstanceGroupId: ig.Id,
+		}
+		_, err := s.EMRAPI.RemoveAutoScalingPolicy(&in)
+		if err != nil {
+			return apiError("RemoveAutoScalingPolicy", err)
+		}
+		fmt.Fprintf(s.Stderr, "removed auto-scaling policy: %s\n", aws.StringValue(ig.Name))
+	}
+
+	fmt.Fprintf(s.Stderr, "removed scaling policies of %s\n", o.Name)
+	return nil
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"strings"
+	"testing"
+)
+
+/*
+ * Test Scaling
+ */
+func TestStartWithManagedScaling(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test-cluster",
+		Vars:            map[string]string{"max": "30"},
+		TemplateOptions: TemplateOptions{Filename: "./testdata/scaling/cluster.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Start command expected to success but failed with %s", err.Error())
+	}
+
+	limits := a.EMRAPI.LastRunJobFlowInput.ManagedScalingPolicy.ComputeLimits
+	if n := aws.Int64Value(limits.MaximumCapacityUnits); 30 != n {
+		t.Errorf("30 expected but got %d", n)
+	}
+	if a.EMRAPI.LastPutManagedScalingPolicyInput != nil {
+		t.Errorf("PutManagedScalingPolicy API is expected not to be called after launch")
+	}
+}
+
+func TestStartWithAutoScaling(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test-cluster",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/scaling/autoscaling.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Start command expected to success but failed with %s", err.Error())
+	}
+
+	// the empty task group is launched with its policy
+	var task *emr.InstanceGroupConfig
+	for _, ig := range a.EMRAPI.LastRunJobFlowInput.Instances.InstanceGroups {
+		if aws.StringValue(ig.Name) == "task" {
+			task = ig
+		}
+	}
+	if task == nil {
+		t.Fatalf("task group expected to be launched")
+	}
+	if task.AutoScalingPolicy == nil {
+		t.Fatalf("auto-scaling policy expected in the task group")
+	}
+	if n := aws.Int64Value(task.AutoScalingPolicy.Constraints.MaxCapacity); 10 != n {
+		t.Errorf("10 expected but got %d", n)
+	}
+	if n := len(a.EMRAPI.PutAutoScalingPolicyInputs); 0 != n {
+		t.Errorf("PutAutoScalingPolicy API is expected not to be called after launch, but called %d times", n)
+	}
+}
+
+func TestScalingManagedAndAutoScaling(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test-cluster",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/scaling/managed-and-autoscaling.yml"},
+	})
+	if _, ok := err.(*ValidationError); !ok {
+		t.Fatalf("ValidationError expected but got %v", err)
+	}
+	if !strings.Contains(err.Error(), "managed and autoscaling cannot be used together") {
+		t.Errorf("managed and autoscaling error expected but got %s", err.Error())
+	}
+	if a.EMRAPI.LastRunJobFlowInput != nil {
+		t.Errorf("RunJobFlow API is expected not to be called")
+	}
+}
+
+func TestScalingUnknownGroup(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.ScalingSet(&AppScalingOptions{
+		Name:            "test",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/scaling/unknown-group.yml"},
+	})
+	if _, ok := err.(*ValidationError); !ok {
+		t.Fatalf("ValidationError expected but got %v", err)
+	}
+	if !strings.Contains(err.Error(), "instance group spot is not defined") {
+		t.Errorf("unknown instance group error expected but got %s", err.Error())
+	}
+}
+
+func TestScalingGet(t *testing.T) {
+	a := NewMockApp()
+	a.EMRAPI.MockGetManagedScalingPolicy = func(input *emr.GetManagedScalingPolicyInput) (*emr.GetManagedScalingPolicyOutput, error) {
+		return &emr.GetManagedScalingPolicyOutput{
+			ManagedScalingPolicy: &emr.ManagedScalingPolicy{
+				ComputeLimits: &emr.ComputeLimits{
+					UnitType:             aws.String("Instances"),
+					MinimumCapacityUnits: aws.Int64(2),
+					MaximumCapacityUnits: aws.Int64(20),
+				},
+			},
+		}, nil
+	}
+
+	err := a.ScalingGet(&AppScalingOptions{Name: "test"})
+	if err != nil {
+		t.Fatalf("ScalingGet expected to success but failed with %s", err.Error())
+	}
+
+	exp := "scaling:\n" +
+		"  managed:\n" +
+		"    maximumcapacityunits: 20\n" +
+		"    minimumcapacityunits: 2\n" +
+		"    unittype: Instances\n"
+	if out := a.Stdout.String(); exp != out {
+		t.Errorf("'%s' expected but got '%s'", exp, out)
+	}
+}
+
+func TestScalingRemove(t *testing.T) {
+	a := NewMockApp()
+	a.EMRAPI.MockListInstanceGroupsPages = func(input *emr.ListInstanceGroupsInput, fn func(*emr.ListInstanceGroupsOutput, bool) bool) error {
+		fn(&emr.ListInstanceGroupsOutput{
+			InstanceGroups: []*emr.InstanceGroup{
+				{Id: aws.String("ig-00000002"), Name: aws.String("core")},
+				{Id: aws.String("ig-00000003"), Name: aws.String("task"), AutoScalingPolicy: &emr.AutoScalingPolicyDescription{}},
+			},
+		}, true)
+		return nil
+	}
+
+	err := a.ScalingRemove(&AppScalingOptions{Name: "test"})
+	if err != nil {
+		t.Fatalf("ScalingRemove expected to success but failed with %s", err.Error())
+	}
+
+	if a.EMRAPI.LastRemoveManagedScalingPolicyInput == nil {
+		t.Errorf("RemoveManagedScalingPolicy API is expected to be called")
+	}
+	removes := a.EMRAPI.RemoveAutoScalingPolicyInputs
+	if len(removes) != 1 || aws.StringValue(removes[0].InstanceGroupId) != "ig-00000003" {
+		t.Errorf("auto-scaling policy of ig-00000003 expected to be removed but got %v", removes)
+	}
+}
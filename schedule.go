@@ -0,0 +1,456 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * Schedule
+ *
+ * The `schedule` section of a template:
+ *
+ *   schedule:
+ *     timezone: Asia/Tokyo
+ *     rules:
+ *     - cron: "0 1 * * *"
+ *       resize: task
+ *       size: 20
+ *     - cron: "0 6 * * *"
+ *       resize: task
+ *       size: 0
+ *     - cron: "0 20 * * fri"
+ *       terminate: true
+ */
+
+// ScheduleConfig is the rules applied to the cluster by `scheduler run`.
+type ScheduleConfig struct {
+	Timezone string          `yaml:"timezone"`
+	Rules    []*ScheduleRule `yaml:"rules"`
+}
+
+// ScheduleRule resizes an instance group or terminates the cluster at the times of the cron expression.
+type ScheduleRule struct {
+	Cron      string `yaml:"cron"`
+	Resize    string `yaml:"resize"` // instance group name
+	Size      string `yaml:"size"`   // size spec accepted by resize
+	Terminate bool   `yaml:"terminate"`
+}
+
+func (r *ScheduleRule) String() string {
+	if r.Terminate {
+		return fmt.Sprintf("%s terminate", r.Cron)
+	}
+	return fmt.Sprintf("%s resize %s %s", r.Cron, r.Resize, r.Size)
+}
+
+// Location returns the timezone of the schedule. Local time is used if it is not given.
+func (c *ScheduleConfig) Location() (*time.Location, error) {
+	if c.Timezone == "" {
+		return time.Local, nil
+	}
+	return time.LoadLocation(c.Timezone)
+}
+
+func validateScheduleConfig(schedule *ScheduleConfig, config *ClusterTemplate, dat []byte) []*ConfigError {
+	if schedule == nil {
+		return nil
+	}
+	var errs []*ConfigError
+	lines := strings.Split(string(dat), "\n")
+
+	if _, err := schedule.Location(); err != nil {
+		errs = append(errs, newConfigError(lines, keyPattern("timezone"), 1,
+			fmt.Sprintf("schedule.timezone: unknown timezone %s", schedule.Timezone)))
+	}
+
+	groups := map[string]bool{}
+	if config.Instances != nil {
+		for _, ig := range config.Instances.InstanceGroups {
+			groups[aws.StringValue(ig.Name)] = true
+		}
+	}
+	for i, r := range schedule.Rules {
+		prefix := fmt.Sprintf("schedule.rules[%d]", i)
+		if r == nil {
+			errs = append(errs, &ConfigError{Message: prefix + ": rule is empty"})
+			continue
+		}
+		if _, err := parseCron(r.Cron); err != nil {
+			errs = append(errs, newConfigError(lines, keyValuePattern("cron", r.Cron), 1,
+				fmt.Sprintf("%s: %s", prefix, err.Error())))
+		}
+		switch {
+		case r.Terminate && r.Resize != "":
+			errs = append(errs, &ConfigError{Message: prefix + ": either resize or terminate expected but got both"})
+		case r.Terminate:
+		case r.Resize == "":
+			errs = append(errs, &ConfigError{Message: prefix + ": resize or terminate is required"})
+		case !groups[r.Resize]:
+			errs = append(errs, newConfigError(lines, keyValuePattern("resize", r.Resize), 1,
+				fmt.Sprintf("%s: instance group %s is not defined", prefix, r.Resize)))
+		default:
+			if _, err := parseResizeSpec(r.Size); err != nil {
+				errs = append(errs, &ConfigError{Message: fmt.Sprintf("%s: %s", prefix, err.Error())})
+			}
+		}
+	}
+	return errs
+}
+
+/*
+ * Cron expressions
+ *
+ *   minute hour day-of-month month day-of-week
+ *
+ * Each field is *, a value, a range (1-5), a step (*\/15, 0-30/10) or a comma separated list of them.
+ * Months and days of week may be given by names (jan, mon). Sunday is 0 or 7.
+ * As in cron, a time matches either day field when both are restricted.
+ */
+
+// CronSchedule is a parsed cron expression.
+type CronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+var (
+	cronMonthNames = []string{"", "jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+	cronDayNames   = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+)
+
+func parseCron(spec string) (*CronSchedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q (5 fields expected)", spec)
+	}
+
+	c := &CronSchedule{
+		domStar: fields[2] == "*",
+		dowStar: fields[4] == "*",
+	}
+	var err error
+	if c.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid minute in %q: %s", spec, err.Error())
+	}
+	if c.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid hour in %q: %s", spec, err.Error())
+	}
+	if c.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid day of month in %q: %s", spec, err.Error())
+	}
+	if c.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, fmt.Errorf("invalid month in %q: %s", spec, err.Error())
+	}
+	if c.dow, err = parseCronField(fields[4], 0, 7, cronDayNames); err != nil {
+		return nil, fmt.Errorf("invalid day of week in %q: %s", spec, err.Error())
+	}
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+// parseCronField returns the bit set of the values matched by the field.
+func parseCronField(field string, min int, max int, names []string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		expr, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %s", item)
+			}
+			expr, step = item[:i], n
+		}
+
+		lo, hi := min, max
+		if expr != "*" {
+			bounds := strings.SplitN(expr, "-", 2)
+			var err error
+			if lo, err = parseCronValue(bounds[0], min, max, names); err != nil {
+				return 0, err
+			}
+			hi = lo
+			if len(bounds) == 2 {
+				if hi, err = parseCronValue(bounds[1], min, max, names); err != nil {
+					return 0, err
+				}
+			} else if step > 1 {
+				hi = max
+			}
+			if hi < lo {
+				return 0, fmt.Errorf("invalid range %s", expr)
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(s string, min int, max int, names []string) (int, error) {
+	for i, n := range names {
+		if n != "" && strings.ToLower(s) == n {
+			return i, nil
+		}
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < min || v > max {
+		return 0, fmt.Errorf("%s is out of range %d-%d", s, min, max)
+	}
+	return v, nil
+}
+
+func (c *CronSchedule) dayMatches(t time.Time) bool {
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domStar || c.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// Next returns the first time matching the schedule after t in the timezone of t.
+// The zero time is returned if nothing matches within 5 years (e.g. 30 Feb).
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	loc := t.Location()
+	t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute()+1, 0, 0, loc)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		switch {
+		case c.month&(1<<uint(t.Month())) == 0:
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case c.hour&(1<<uint(t.Hour())) == 0:
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case c.minute&(1<<uint(t.Minute())) == 0:
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Last returns the latest time matching the schedule in (from, to], or the zero time if there is none.
+func (c *CronSchedule) Last(from time.Time, to time.Time) time.Time {
+	var last time.Time
+	for t := c.Next(from); !t.IsZero() && !t.After(to); t = c.Next(t) {
+		last = t
+	}
+	return last
+}
+
+/*
+ * SCHEDULER command
+ */
+type AppSchedulerOptions struct {
+	Names []string
+	Vars  map[string]string
+	TemplateOptions
+	StateFile  string
+	Interval   time.Duration
+	MaxDelay   time.Duration // rules due longer ago than this are skipped
+	Iterations int           // number of evaluations, 0 to run forever
+	DryRun     bool
+}
+
+// scheduler keeps the time each rule was evaluated last per cluster id.
+// The state is saved to StateFile so that a restarted scheduler does not apply a rule twice.
+type scheduler struct {
+	app   *App
+	opts  *AppSchedulerOptions
+	state map[string]time.Time
+}
+
+// SchedulerRun evaluates the schedule of the clusters every Interval until Iterations evaluations are done.
+func (s *App) SchedulerRun(o *AppSchedulerOptions) error {
+	state, err := readSchedulerState(o.StateFile)
+	if err != nil {
+		return err
+	}
+
+	sc := &scheduler{app: s, opts: o, state: state}
+	for i := 0; o.Iterations == 0 || i < o.Iterations; i++ {
+		if i > 0 {
+			time.Sleep(o.Interval)
+		}
+		err := sc.tick(time.Now())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// tick applies the rules due since the last evaluation to every cluster.
+// Clusters which are not active are skipped. Failures are logged and do not stop the scheduler.
+func (sc *scheduler) tick(now time.Time) error {
+	for _, name := range sc.opts.Names {
+		err := sc.evaluate(now, name)
+		if err != nil {
+			if _, ok := err.(*NotFoundError); ok {
+				continue
+			}
+			sc.log(now, "%s: %s", name, err.Error())
+		}
+	}
+	return nil
+}
+
+func (sc *scheduler) evaluate(now time.Time, name string) error {
+	s, o := sc.app, sc.opts
+
+	c, err := s.FindByName(name)
+	if err != nil {
+		return err
+	}
+	id := aws.StringValue(c.Id)
+
+	filename, err := s.ConfigFile(o.Filename, o.Profile)
+	if err != nil {
+		return err
+	}
+	vars := map[string]string{"name": name}
+	for k, v := range o.Vars {
+		vars[k] = v
+	}
+	loader, err := s.newConfigLoader(name, vars, o.VarFiles)
+	if err != nil {
+		return err
+	}
+	t, err := loader.LoadTemplate(filename)
+	if err != nil {
+		return err
+	}
+	if t.Schedule == nil {
+		return fmt.Errorf("%s has no schedule section", filename)
+	}
+	loc, err := t.Schedule.Location()
+	if err != nil {
+		return err
+	}
+
+	// rules never evaluated for the cluster are due since it was created
+	var created time.Time
+	if c.Status != nil && c.Status.Timeline != nil {
+		created = aws.TimeValue(c.Status.Timeline.CreationDateTime)
+	}
+
+	for _, r := range t.Schedule.Rules {
+		cron, err := parseCron(r.Cron)
+		if err != nil {
+			return err
+		}
+
+		key := id + " " + r.String()
+		from, ok := sc.state[key]
+		if !ok {
+			from = created
+		}
+		if min := now.Add(-o.MaxDelay); from.Before(min) {
+			from = min
+		}
+		due := cron.Last(from.In(loc), now.In(loc))
+		if due.IsZero() {
+			continue
+		}
+
+		// save the state first, so that a crash while applying never applies the rule twice
+		sc.state[key] = now
+		if !o.DryRun {
+			err := writeSchedulerState(o.StateFile, sc.state)
+			if err != nil {
+				return err
+			}
+		}
+		sc.apply(now, name, vars, r, due)
+	}
+	return nil
+}
+
+// apply resizes or terminates the cluster. Errors are logged, and the rule is not retried until it is due again.
+func (sc *scheduler) apply(now time.Time, name string, vars map[string]string, r *ScheduleRule, due time.Time) {
+	s, o := sc.app, sc.opts
+
+	var action string
+	if r.Terminate {
+		action = "terminate"
+	} else {
+		action = fmt.Sprintf("resize %s to %s", r.Resize, r.Size)
+	}
+	if o.DryRun {
+		sc.log(now, "%s: %s (dry-run): %s due at %s", name, action, r.Cron, due.Format(time.RFC3339))
+		return
+	}
+	sc.log(now, "%s: %s: %s due at %s", name, action, r.Cron, due.Format(time.RFC3339))
+
+	var err error
+	if r.Terminate {
+		err = s.Terminate(&AppTerminateOptions{Names: []string{name}, Yes: true})
+	} else {
+		err = s.Resize(&AppResizeOptions{
+			Name:              name,
+			InstanceGroupName: r.Resize,
+			Size:              r.Size,
+			Vars:              vars,
+			TemplateOptions:   o.TemplateOptions,
+		})
+	}
+	if err != nil {
+		sc.log(now, "%s: %s failed: %s", name, action, err.Error())
+	}
+}
+
+func (sc *scheduler) log(now time.Time, format string, args ...interface{}) {
+	fmt.Fprintf(sc.app.Stderr, "%s %s\n", now.Format(time.RFC3339), fmt.Sprintf(format, args...))
+}
+
+func readSchedulerState(filename string) (map[string]time.Time, error) {
+	state := map[string]time.Time{}
+	if filename == "" {
+		return state, nil
+	}
+	buf, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = json.Unmarshal(buf, &state)
+	if err != nil {
+		return nil, fmt.Errorf("%s is broken: %s", filename, err.Error())
+	}
+	return state, nil
+}
+
+// writeSchedulerState replaces the state file atomically so that it is never left half-written.
+func writeSchedulerState(filename string, state map[string]time.Time) error {
+	if filename == "" {
+		return nil
+	}
+	buf, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(path.Dir(filename), 0700); err != nil {
+		return err
+	}
+	tmp := filename + ".tmp"
+	if err := ioutil.WriteFile(tmp, buf, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filename)
+}
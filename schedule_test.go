@@ -0,0 +1,178 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+/*
+ * Test cron expressions
+ */
+func TestCronNext(t *testing.T) {
+	from := time.Date(2026, 10, 18, 0, 30, 0, 0, time.UTC) // Sunday
+	cases := []struct {
+		spec string
+		next time.Time
+	}{
+		{"0 1 * * *", time.Date(2026, 10, 18, 1, 0, 0, 0, time.UTC)},
+		{"0 20 * * fri", time.Date(2026, 10, 23, 20, 0, 0, 0, time.UTC)},
+		{"*/15 9-17 * * mon-fri", time.Date(2026, 10, 19, 9, 0, 0, 0, time.UTC)},
+		{"45 * * * *", time.Date(2026, 10, 18, 0, 45, 0, 0, time.UTC)},
+		{"0 0 1,15 * mon", time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan *", time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, c := range cases {
+		cron, err := parseCron(c.spec)
+		if err != nil {
+			t.Fatalf("%s expected to be parsed but failed with %s", c.spec, err.Error())
+		}
+		if next := cron.Next(from); !next.Equal(c.next) {
+			t.Errorf("%s: %s expected but got %s", c.spec, c.next, next)
+		}
+	}
+}
+
+func TestCronInvalid(t *testing.T) {
+	for _, spec := range []string{"0 1 * *", "60 * * * *", "0 1-0 * * *", "*/0 * * * *", "0 0 * * foo"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("%s expected to be invalid", spec)
+		}
+	}
+}
+
+func TestCronTimezone(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skip("Asia/Tokyo is not available")
+	}
+	cron, _ := parseCron("0 1 * * *")
+
+	from := time.Date(2026, 10, 18, 15, 0, 0, 0, time.UTC)
+	next := cron.Next(from.In(tokyo))
+	if exp := time.Date(2026, 10, 18, 16, 0, 0, 0, time.UTC); !next.Equal(exp) {
+		t.Errorf("%s expected but got %s", exp, next)
+	}
+}
+
+/*
+ * Test Scheduler
+ */
+func newTestScheduler(a *MockApp, state string) *scheduler {
+	s, _ := readSchedulerState(state)
+	return &scheduler{
+		app: &a.App,
+		opts: &AppSchedulerOptions{
+			Names:           []string{"test", "missing"},
+			TemplateOptions: TemplateOptions{Filename: "./testdata/schedule/cluster.yml"},
+			StateFile:       state,
+			MaxDelay:        time.Hour,
+		},
+		state: s,
+	}
+}
+
+func TestSchedulerRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "emrcmd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	state := path.Join(dir, "scheduler-state.json")
+
+	// 01:00:30 in Asia/Tokyo
+	now := time.Date(2026, 10, 17, 16, 0, 30, 0, time.UTC)
+
+	a := NewMockApp()
+	// the state is saved before the rule is applied, so that a crash while applying never applies it twice
+	saved := 0
+	a.EMRAPI.MockAddInstanceGroups = func(input *emr.AddInstanceGroupsInput) (*emr.AddInstanceGroupsOutput, error) {
+		s, _ := readSchedulerState(state)
+		saved = len(s)
+		return &emr.AddInstanceGroupsOutput{}, nil
+	}
+	err = newTestScheduler(a, state).tick(now)
+	if err != nil {
+		t.Fatalf("scheduler expected to success but failed with %s", err.Error())
+	}
+
+	input := a.EMRAPI.LastAddInstanceGroupsInput
+	if input == nil {
+		t.Fatalf("AddInstanceGroups API is expected to be called")
+	}
+	if n := aws.Int64Value(input.InstanceGroups[0].InstanceCount); 20 != n {
+		t.Errorf("20 expected but got %d", n)
+	}
+	if saved == 0 {
+		t.Errorf("state expected to be saved before AddInstanceGroups")
+	}
+	if a.EMRAPI.LastTerminateJobFlowsInput != nil {
+		t.Errorf("TerminateJobFlows API is expected not to be called")
+	}
+	exp := "2026-10-17T16:00:30Z test: resize task to 20: 0 1 * * * due at 2026-10-18T01:00:00+09:00"
+	if msg := a.Stderr.String(); !strings.Contains(msg, exp) {
+		t.Errorf("'%s' expected in '%s'", exp, msg)
+	}
+
+	// restarted scheduler does not apply the rule again
+	a = NewMockApp()
+	err = newTestScheduler(a, state).tick(now.Add(time.Minute))
+	if err != nil {
+		t.Fatalf("scheduler expected to success but failed with %s", err.Error())
+	}
+	if a.EMRAPI.LastAddInstanceGroupsInput != nil {
+		t.Errorf("AddInstanceGroups API is expected not to be called after restart")
+	}
+}
+
+func TestSchedulerMaxDelay(t *testing.T) {
+	a := NewMockApp()
+	sc := newTestScheduler(a, "")
+	sc.opts.DryRun = true
+
+	// 20:30 on Friday in Asia/Tokyo: terminate is due, resizes are too old
+	err := sc.tick(time.Date(2026, 10, 23, 11, 30, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("scheduler expected to success but failed with %s", err.Error())
+	}
+
+	if a.EMRAPI.LastTerminateJobFlowsInput != nil {
+		t.Errorf("TerminateJobFlows API is expected not to be called in dry-run")
+	}
+	msg := a.Stderr.String()
+	if exp := "test: terminate (dry-run): 0 20 * * fri due at 2026-10-23T20:00:00+09:00"; !strings.Contains(msg, exp) {
+		t.Errorf("'%s' expected in '%s'", exp, msg)
+	}
+	if strings.Contains(msg, "resize") {
+		t.Errorf("resize is not expected in '%s'", msg)
+	}
+}
+
+func TestScheduleInvalid(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Validate(&AppValidateOptions{
+		Name:            "test",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/schedule/invalid.yml"},
+	})
+	if _, ok := err.(*ValidationError); !ok {
+		t.Fatalf("ValidationError expected but got %v", err)
+	}
+	for _, exp := range []string{
+		"schedule.timezone: unknown timezone Mars/Olympus",
+		`schedule.rules[0]: invalid hour in "0 25 * * *"`,
+		"schedule.rules[1]: instance group gpu is not defined",
+		"schedule.rules[2]: resize or terminate is required",
+	} {
+		if !strings.Contains(err.Error(), exp) {
+			t.Errorf("'%s' expected in '%s'", exp, err.Error())
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/aws/aws-sdk-go/service/secretsmanager/secretsmanageriface"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/aws/aws-sdk-go/service/ssm/ssmiface"
+	"sort"
+	"strings"
+)
+
+// SecretMask replaces secret values in dry-run output and error messages.
+const SecretMask = "********"
+
+// SecretStore fetches secret values referred from templates.
+type SecretStore interface {
+	GetParameter(name string) (string, error)
+	GetSecretValue(id string) (string, error)
+}
+
+// AWSSecretStore reads secrets from SSM Parameter Store and Secrets Manager.
+type AWSSecretStore struct {
+	SSMAPI            ssmiface.SSMAPI
+	SecretsManagerAPI secretsmanageriface.SecretsManagerAPI
+}
+
+func (s *AWSSecretStore) GetParameter(name string) (string, error) {
+	in := ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(true),
+	}
+	out, err := s.SSMAPI.GetParameter(&in)
+	if err != nil {
+		return "", apiError("GetParameter", err)
+	}
+	return aws.StringValue(out.Parameter.Value), nil
+}
+
+func (s *AWSSecretStore) GetSecretValue(id string) (string, error) {
+	in := secretsmanager.GetSecretValueInput{SecretId: aws.String(id)}
+	out, err := s.SecretsManagerAPI.GetSecretValue(&in)
+	if err != nil {
+		return "", apiError("GetSecretValue", err)
+	}
+	return aws.StringValue(out.SecretString), nil
+}
+
+/*
+ * Template functions
+ */
+
+// ssm returns the (decrypted) value of the SSM parameter.
+func (l *configLoader) ssm(name string) (string, error) {
+	if l.secrets == nil {
+		return "", fmt.Errorf("ssm %s: secret store is not available", name)
+	}
+	val, err := l.secrets.GetParameter(name)
+	if err != nil {
+		return "", err
+	}
+	l.addSecret(val)
+	return val, nil
+}
+
+// secret returns the secret string, or the value of the key if the secret is a JSON object.
+func (l *configLoader) secret(id string, keys ...string) (string, error) {
+	if l.secrets == nil {
+		return "", fmt.Errorf("secret %s: secret store is not available", id)
+	}
+	val, err := l.secrets.GetSecretValue(id)
+	if err != nil {
+		return "", err
+	}
+
+	if len(keys) > 0 {
+		m := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(val), &m); err != nil {
+			return "", fmt.Errorf("secret %s is not a JSON object", id)
+		}
+		v, ok := m[keys[0]]
+		if !ok {
+			return "", &NotFoundError{Kind: "secret key", Name: keys[0]}
+		}
+		val = fmt.Sprint(v)
+	}
+
+	l.addSecret(val)
+	return val, nil
+}
+
+func (l *configLoader) addSecret(val string) {
+	if val == "" {
+		return
+	}
+	l.secretValues = append(l.secretValues, val)
+	// mask longer values first not to leave a part of them
+	sort.Slice(l.secretValues, func(i, j int) bool { return len(l.secretValues[i]) > len(l.secretValues[j]) })
+}
+
+// Mask replaces secret values fetched while rendering in s.
+func (l *configLoader) Mask(s string) string {
+	for _, v := range l.secretValues {
+		s = strings.Replace(s, v, SecretMask, -1)
+	}
+	return s
+}
+
+// maskError hides secret values in validation errors, which quote the rendered template.
+func (l *configLoader) maskError(err error) error {
+	if err == nil || len(l.secretValues) == 0 {
+		return err
+	}
+	if verr, ok := err.(*ValidationError); ok {
+		for _, ce := range verr.Errors {
+			ce.Text = l.Mask(ce.Text)
+			ce.Message = l.Mask(ce.Message)
+		}
+		return verr
+	}
+	if msg := l.Mask(err.Error()); msg != err.Error() {
+		return fmt.Errorf("%s", msg)
+	}
+	return err
+}
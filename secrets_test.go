@@ -0,0 +1,127 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"strings"
+	"testing"
+)
+
+func mockSecrets(a *MockApp) {
+	a.Secrets.Parameters = map[string]string{
+		"/emr/metastore/url": "jdbc:mysql://metastore:3306/hive",
+	}
+	a.Secrets.Secrets = map[string]string{
+		"arn:aws:secretsmanager:metastore": `{"username": "metastore_user", "password": "p@ss\"word"}`,
+	}
+}
+
+/*
+ * Test secrets
+ */
+func TestStartWithSecrets(t *testing.T) {
+	a := NewMockApp()
+	mockSecrets(a)
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/secrets/cluster.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Start command expected to success but failed with %s", err.Error())
+	}
+
+	props := a.EMRAPI.LastRunJobFlowInput.Configurations[0].Properties
+	exp := map[string]string{
+		"javax.jdo.option.ConnectionUserName": "metastore_user",
+		"javax.jdo.option.ConnectionPassword": `p@ss"word`,
+		"javax.jdo.option.ConnectionURL":      "jdbc:mysql://metastore:3306/hive",
+	}
+	for k, v := range exp {
+		if got := aws.StringValue(props[k]); v != got {
+			t.Errorf("%s: '%s' expected but got '%s'", k, v, got)
+		}
+	}
+}
+
+func TestStartDryRunMasksSecrets(t *testing.T) {
+	for _, format := range []string{"yaml", "json"} {
+		a := NewMockApp()
+		mockSecrets(a)
+
+		err := a.Start(&AppStartOptions{
+			Name:            "test",
+			TemplateOptions: TemplateOptions{Filename: "./testdata/secrets/cluster.yml"},
+			DryRun:          true,
+			Output:          format,
+		})
+		if err != nil {
+			t.Fatalf("Start command expected to success but failed with %s", err.Error())
+		}
+
+		out := a.Stdout.String()
+		for _, secret := range []string{"metastore_user", "p@ss", "metastore:3306"} {
+			if strings.Contains(out, secret) {
+				t.Errorf("secret '%s' is expected to be masked in %s but got '%s'", secret, format, out)
+			}
+		}
+		if !strings.Contains(out, SecretMask) {
+			t.Errorf("masked value expected in %s but got '%s'", format, out)
+		}
+	}
+}
+
+func TestStartExplainMasksSecrets(t *testing.T) {
+	a := NewMockApp()
+	mockSecrets(a)
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/secrets/explain.yml"},
+		DryRun:          true,
+		Explain:         true,
+	})
+	if err != nil {
+		t.Fatalf("Start command expected to success but failed with %s", err.Error())
+	}
+
+	msg := a.Stderr.String()
+	if strings.Contains(msg, "p@ss") {
+		t.Errorf("secret is expected to be masked but got '%s'", msg)
+	}
+	if exp := "db_password = " + SecretMask + " (default)"; !strings.Contains(msg, exp) {
+		t.Errorf("'%s' expected in '%s'", exp, msg)
+	}
+}
+
+func TestVarsMasksSecrets(t *testing.T) {
+	a := NewMockApp()
+	mockSecrets(a)
+
+	err := a.Vars(&AppVarsOptions{
+		Name:            "test",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/secrets/explain.yml"},
+		Vars:            map[string]string{"db_password": `p@ss"word`},
+	})
+	if err != nil {
+		t.Fatalf("Vars command expected to success but failed with %s", err.Error())
+	}
+
+	if out := a.Stdout.String(); strings.Contains(out, "p@ss") {
+		t.Errorf("secret is expected to be masked but got '%s'", out)
+	}
+}
+
+func TestStartWithMissingSecret(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/secrets/cluster.yml"},
+	})
+	if err == nil {
+		t.Fatalf("Start command expected to fail but succeeded")
+	}
+	if a.EMRAPI.LastRunJobFlowInput != nil {
+		t.Errorf("RunJobFlow API is expected not to be called but called")
+	}
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+ * Spot prices
+ */
+
+// SpotPriceLookback is how far back the spotprice template function looks for the current prices.
+const SpotPriceLookback = time.Hour
+
+// SpotPrice is the spot price of an instance type in an availability zone since Timestamp.
+type SpotPrice struct {
+	InstanceType     string
+	AvailabilityZone string
+	Price            float64
+	Timestamp        time.Time
+}
+
+// SpotPriceSource fetches the spot price history and the zones of subnets.
+type SpotPriceSource interface {
+	SpotPriceHistory(instanceTypes []string, since time.Time) ([]*SpotPrice, error)
+	SubnetZones(subnetIds []string) (map[string]string, error)
+}
+
+// AWSSpotPriceSource reads spot prices of Linux instances from EC2.
+type AWSSpotPriceSource struct {
+	EC2API ec2iface.EC2API
+}
+
+func (s *AWSSpotPriceSource) SpotPriceHistory(instanceTypes []string, since time.Time) ([]*SpotPrice, error) {
+	in := ec2.DescribeSpotPriceHistoryInput{
+		InstanceTypes:       aws.StringSlice(instanceTypes),
+		ProductDescriptions: aws.StringSlice([]string{"Linux/UNIX"}),
+		StartTime:           aws.Time(since),
+	}
+	var ret []*SpotPrice
+	var perr error
+	err := s.EC2API.DescribeSpotPriceHistoryPages(&in, func(out *ec2.DescribeSpotPriceHistoryOutput, b bool) bool {
+		for _, h := range out.SpotPriceHistory {
+			price, err := strconv.ParseFloat(aws.StringValue(h.SpotPrice), 64)
+			if err != nil {
+				perr = fmt.Errorf("invalid spot price %s of %s", aws.StringValue(h.SpotPrice), aws.StringValue(h.InstanceType))
+				return false
+			}
+			ret = append(ret, &SpotPrice{
+				InstanceType:     aws.StringValue(h.InstanceType),
+				AvailabilityZone: aws.StringValue(h.AvailabilityZone),
+				Price:            price,
+				Timestamp:        aws.TimeValue(h.Timestamp),
+			})
+		}
+		return true
+	})
+	if err != nil {
+		return nil, apiError("DescribeSpotPriceHistory", err)
+	}
+	if perr != nil {
+		return nil, perr
+	}
+	return ret, nil
+}
+
+func (s *AWSSpotPriceSource) SubnetZones(subnetIds []string) (map[string]string, error) {
+	out, err := s.EC2API.DescribeSubnets(&ec2.DescribeSubnetsInput{SubnetIds: aws.StringSlice(subnetIds)})
+	if err != nil {
+		return nil, apiError("DescribeSubnets", err)
+	}
+	ret := map[string]string{}
+	for _, n := range out.Subnets {
+		ret[aws.StringValue(n.SubnetId)] = aws.StringValue(n.AvailabilityZone)
+	}
+	return ret, nil
+}
+
+// latestSpotPrices returns the latest price of each instance type and zone, keyed by "TYPE ZONE".
+func latestSpotPrices(history []*SpotPrice) map[string]*SpotPrice {
+	ret := map[string]*SpotPrice{}
+	for _, p := range history {
+		k := p.InstanceType + " " + p.AvailabilityZone
+		if l, ok := ret[k]; !ok || p.Timestamp.After(l.Timestamp) {
+			ret[k] = p
+		}
+	}
+	return ret
+}
+
+// parseSpotMultiplier parses the multiplier of the spotprice function, e.g. 1.2x or 1.2.
+func parseSpotMultiplier(s string) (float64, error) {
+	m, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || m <= 0 {
+		return 0, fmt.Errorf("invalid multiplier %s (e.g. 1.2x expected)", s)
+	}
+	return m, nil
+}
+
+/*
+ * Template functions
+ */
+
+// spotprice returns the current spot price of the instance type times the multiplier, e.g. `spotprice "m5.xlarge" "1.2x"`.
+// The highest price among the zones is taken, so that the bid is valid wherever the cluster is placed.
+func (l *configLoader) spotprice(instanceType string, multiplier ...string) (string, error) {
+	if l.spotPrices == nil {
+		return "", fmt.Errorf("spotprice %s: spot price source is not available", instanceType)
+	}
+
+	m := 1.0
+	if len(multiplier) > 0 {
+		var err error
+		m, err = parseSpotMultiplier(multiplier[0])
+		if err != nil {
+			return "", fmt.Errorf("spotprice %s: %s", instanceType, err.Error())
+		}
+	}
+
+	price, ok := l.spotPriceCache[instanceType]
+	if !ok {
+		history, err := l.spotPrices.SpotPriceHistory([]string{instanceType}, time.Now().Add(-SpotPriceLookback))
+		if err != nil {
+			return "", err
+		}
+		for _, p := range latestSpotPrices(history) {
+			if p.Price > price {
+				price = p.Price
+			}
+		}
+		if price == 0 {
+			return "", &NotFoundError{Kind: "spot price", Name: instanceType}
+		}
+		if l.spotPriceCache == nil {
+			l.spotPriceCache = map[string]float64{}
+		}
+		l.spotPriceCache[instanceType] = price
+	}
+
+	return strconv.FormatFloat(price*m, 'f', 4, 64), nil
+}
+
+/*
+ * SPOT-PRICES command
+ */
+type AppSpotPricesOptions struct {
+	Types   []string
+	Subnets []string      // show the prices per subnet instead of per zone
+	Since   time.Duration // range of the minimum and maximum prices
+}
+
+// spotPriceRow is a row of the spot-prices table.
+type spotPriceRow struct {
+	InstanceType     string
+	AvailabilityZone string
+	Subnet           string
+	Latest, Min, Max float64 // all 0 if no price is known
+}
+
+// SpotPrices prints the latest, minimum and maximum spot prices of the instance types per zone or subnet, cheapest first.
+func (s *App) SpotPrices(o *AppSpotPricesOptions) error {
+	if s.SpotPricing == nil {
+		return fmt.Errorf("spot price source is not available")
+	}
+
+	history, err := s.SpotPricing.SpotPriceHistory(o.Types, time.Now().Add(-o.Since))
+	if err != nil {
+		return err
+	}
+	latest := latestSpotPrices(history)
+
+	rows := map[string]*spotPriceRow{}
+	for k, p := range latest {
+		rows[k] = &spotPriceRow{InstanceType: p.InstanceType, AvailabilityZone: p.AvailabilityZone, Subnet: "-",
+			Latest: p.Price, Min: p.Price, Max: p.Price}
+	}
+	for _, p := range history {
+		r := rows[p.InstanceType+" "+p.AvailabilityZone]
+		if p.Price < r.Min {
+			r.Min = p.Price
+		}
+		if p.Price > r.Max {
+			r.Max = p.Price
+		}
+	}
+
+	var ret []*spotPriceRow
+	if len(o.Subnets) == 0 {
+		for _, r := range rows {
+			ret = append(ret, r)
+		}
+	} else {
+		zones, err := s.SpotPricing.SubnetZones(o.Subnets)
+		if err != nil {
+			return err
+		}
+		for _, subnet := range o.Subnets {
+			zone, ok := zones[subnet]
+			if !ok {
+				return &NotFoundError{Kind: "subnet", Name: subnet}
+			}
+			for _, t := range o.Types {
+				r := spotPriceRow{InstanceType: t, AvailabilityZone: zone}
+				if z, ok := rows[t+" "+zone]; ok {
+					r = *z
+				}
+				r.Subnet = subnet
+				ret = append(ret, &r)
+			}
+		}
+	}
+	if len(ret) == 0 {
+		return &NotFoundError{Kind: "spot price", Name: strings.Join(o.Types, ",")}
+	}
+
+	// unknown prices last
+	sort.Slice(ret, func(i, j int) bool {
+		a, b := ret[i], ret[j]
+		if a.InstanceType != b.InstanceType {
+			return a.InstanceType < b.InstanceType
+		}
+		if (a.Latest == 0) != (b.Latest == 0) {
+			return b.Latest == 0
+		}
+		if a.Latest != b.Latest {
+			return a.Latest < b.Latest
+		}
+		return a.AvailabilityZone+a.Subnet < b.AvailabilityZone+b.Subnet
+	})
+	writeSpotPrices(s.Stdout, ret)
+	return nil
+}
+
+func writeSpotPrices(w io.Writer, rows []*spotPriceRow) {
+	tw, zw, sw := len("TYPE"), len("ZONE"), len("SUBNET")
+	for _, r := range rows {
+		if len(r.InstanceType) > tw {
+			tw = len(r.InstanceType)
+		}
+		if len(r.AvailabilityZone) > zw {
+			zw = len(r.AvailabilityZone)
+		}
+		if len(r.Subnet) > sw {
+			sw = len(r.Subnet)
+		}
+	}
+
+	format := fmt.Sprintf("%%-%ds  %%-%ds  %%-%ds  %%8s  %%8s  %%8s\n", tw, zw, sw)
+	fmt.Fprintf(w, format, "TYPE", "ZONE", "SUBNET", "PRICE", "MIN", "MAX")
+	for _, r := range rows {
+		if r.Latest == 0 {
+			fmt.Fprintf(w, format, r.InstanceType, r.AvailabilityZone, r.Subnet, "-", "-", "-")
+			continue
+		}
+		fmt.Fprintf(w, format, r.InstanceType, r.AvailabilityZone, r.Subnet,
+			fmt.Sprintf("%.4f", r.Latest), fmt.Sprintf("%.4f", r.Min), fmt.Sprintf("%.4f", r.Max))
+	}
+}
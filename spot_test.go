@@ -0,0 +1,212 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ec2"
+	"github.com/aws/aws-sdk-go/service/ec2/ec2iface"
+	"strings"
+	"testing"
+	"time"
+)
+
+type MockSpotPriceSource struct {
+	History []*SpotPrice
+	Subnets map[string]string
+	Calls   int
+}
+
+func (m *MockSpotPriceSource) SpotPriceHistory(instanceTypes []string, since time.Time) ([]*SpotPrice, error) {
+	m.Calls++
+	var ret []*SpotPrice
+	for _, p := range m.History {
+		for _, t := range instanceTypes {
+			if p.InstanceType == t {
+				ret = append(ret, p)
+			}
+		}
+	}
+	return ret, nil
+}
+
+func (m *MockSpotPriceSource) SubnetZones(subnetIds []string) (map[string]string, error) {
+	ret := map[string]string{}
+	for _, id := range subnetIds {
+		if z, ok := m.Subnets[id]; ok {
+			ret[id] = z
+		}
+	}
+	return ret, nil
+}
+
+// mockSpotPrices mocks spot prices of m3.xlarge in two zones and of r5.2xlarge in one zone.
+func mockSpotPrices(a *MockApp) *MockSpotPriceSource {
+	now := time.Now()
+	price := func(instanceType string, zone string, price float64, ago time.Duration) *SpotPrice {
+		return &SpotPrice{InstanceType: instanceType, AvailabilityZone: zone, Price: price, Timestamp: now.Add(-ago)}
+	}
+	m := &MockSpotPriceSource{
+		History: []*SpotPrice{
+			price("m3.xlarge", "us-east-1a", 0.0800, 5*time.Hour),
+			price("m3.xlarge", "us-east-1a", 0.0750, time.Hour),
+			price("m3.xlarge", "us-east-1b", 0.0700, 3*time.Hour),
+			price("m3.xlarge", "us-east-1b", 0.0500, 2*time.Hour),
+			price("r5.2xlarge", "us-east-1a", 0.1500, 4*time.Hour),
+		},
+		Subnets: map[string]string{
+			"subnet-00000001": "us-east-1a",
+			"subnet-00000002": "us-east-1b",
+			"subnet-00000003": "us-east-1c",
+		},
+	}
+	a.SpotPricing = m
+	return m
+}
+
+/*
+ * Test spotprice template function
+ */
+func TestStartWithSpotPrice(t *testing.T) {
+	a := NewMockApp()
+	m := mockSpotPrices(a)
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test-cluster",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/spot/cluster.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Start command expected to success but failed with %s", err.Error())
+	}
+
+	groups := a.EMRAPI.LastRunJobFlowInput.Instances.InstanceGroups
+	// the latest price of the most expensive zone times the multiplier
+	for i, exp := range map[int]string{1: "0.0900", 2: "0.1500"} {
+		if got := aws.StringValue(groups[i].BidPrice); exp != got {
+			t.Errorf("%s: bid price %s expected but got %s", aws.StringValue(groups[i].Name), exp, got)
+		}
+	}
+	if m.Calls != 2 {
+		t.Errorf("spot prices expected to be fetched once per type, but fetched %d times", m.Calls)
+	}
+}
+
+func TestSpotPriceFunctionErrors(t *testing.T) {
+	cases := []struct {
+		source  SpotPriceSource
+		args    []string
+		message string
+	}{
+		{nil, []string{"m3.xlarge"}, "spotprice m3.xlarge: spot price source is not available"},
+		{&MockSpotPriceSource{}, []string{"m3.xlarge"}, "spot price m3.xlarge is not found"},
+		{&MockSpotPriceSource{}, []string{"m3.xlarge", "twice"}, "spotprice m3.xlarge: invalid multiplier twice (e.g. 1.2x expected)"},
+	}
+	for _, c := range cases {
+		l := newConfigLoader("test", nil)
+		l.spotPrices = c.source
+		_, err := l.spotprice(c.args[0], c.args[1:]...)
+		if err == nil {
+			t.Fatalf("spotprice %v expected to fail", c.args)
+		}
+		if !strings.Contains(err.Error(), c.message) {
+			t.Errorf("'%s' expected but got '%s'", c.message, err.Error())
+		}
+	}
+}
+
+/*
+ * Test SpotPrices
+ */
+func TestSpotPrices(t *testing.T) {
+	a := NewMockApp()
+	mockSpotPrices(a)
+
+	err := a.SpotPrices(&AppSpotPricesOptions{
+		Types: []string{"r5.2xlarge", "m3.xlarge"},
+		Since: 6 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("SpotPrices command expected to success but failed with %s", err.Error())
+	}
+
+	exp := `TYPE        ZONE        SUBNET     PRICE       MIN       MAX
+m3.xlarge   us-east-1b  -         0.0500    0.0500    0.0700
+m3.xlarge   us-east-1a  -         0.0750    0.0750    0.0800
+r5.2xlarge  us-east-1a  -         0.1500    0.1500    0.1500
+`
+	if out := a.Stdout.String(); exp != out {
+		t.Errorf("'%s' expected but got '%s'", exp, out)
+	}
+}
+
+func TestSpotPricesPerSubnet(t *testing.T) {
+	a := NewMockApp()
+	mockSpotPrices(a)
+
+	err := a.SpotPrices(&AppSpotPricesOptions{
+		Types:   []string{"m3.xlarge"},
+		Subnets: []string{"subnet-00000001", "subnet-00000002", "subnet-00000003"},
+		Since:   6 * time.Hour,
+	})
+	if err != nil {
+		t.Fatalf("SpotPrices command expected to success but failed with %s", err.Error())
+	}
+
+	exp := `TYPE       ZONE        SUBNET              PRICE       MIN       MAX
+m3.xlarge  us-east-1b  subnet-00000002    0.0500    0.0500    0.0700
+m3.xlarge  us-east-1a  subnet-00000001    0.0750    0.0750    0.0800
+m3.xlarge  us-east-1c  subnet-00000003         -         -         -
+`
+	if out := a.Stdout.String(); exp != out {
+		t.Errorf("'%s' expected but got '%s'", exp, out)
+	}
+
+	err = a.SpotPrices(&AppSpotPricesOptions{
+		Types:   []string{"m3.xlarge"},
+		Subnets: []string{"subnet-99999999"},
+		Since:   6 * time.Hour,
+	})
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Errorf("NotFoundError expected for an unknown subnet but got %v", err)
+	}
+}
+
+/*
+ * Test AWSSpotPriceSource
+ */
+type MockEC2 struct {
+	ec2iface.EC2API
+	LastDescribeSpotPriceHistoryInput *ec2.DescribeSpotPriceHistoryInput
+}
+
+func (m *MockEC2) DescribeSpotPriceHistoryPages(input *ec2.DescribeSpotPriceHistoryInput, fn func(*ec2.DescribeSpotPriceHistoryOutput, bool) bool) error {
+	m.LastDescribeSpotPriceHistoryInput = input
+	now := time.Now()
+	fn(&ec2.DescribeSpotPriceHistoryOutput{SpotPriceHistory: []*ec2.SpotPrice{
+		{InstanceType: aws.String("m5.xlarge"), AvailabilityZone: aws.String("us-east-1a"), SpotPrice: aws.String("0.080000"), Timestamp: aws.Time(now)},
+	}}, false)
+	fn(&ec2.DescribeSpotPriceHistoryOutput{SpotPriceHistory: []*ec2.SpotPrice{
+		{InstanceType: aws.String("m5.xlarge"), AvailabilityZone: aws.String("us-east-1b"), SpotPrice: aws.String("0.070000"), Timestamp: aws.Time(now)},
+	}}, true)
+	return nil
+}
+
+func TestAWSSpotPriceSource(t *testing.T) {
+	m := &MockEC2{}
+	s := &AWSSpotPriceSource{EC2API: m}
+
+	since := time.Now().Add(-time.Hour)
+	prices, err := s.SpotPriceHistory([]string{"m5.xlarge"}, since)
+	if err != nil {
+		t.Fatalf("SpotPriceHistory expected to success but failed with %s", err.Error())
+	}
+	if len(prices) != 2 || prices[0].Price != 0.08 || prices[1].AvailabilityZone != "us-east-1b" {
+		t.Errorf("prices of both pages expected but got %v", prices)
+	}
+
+	in := m.LastDescribeSpotPriceHistoryInput
+	if got := aws.StringValueSlice(in.ProductDescriptions); len(got) != 1 || got[0] != "Linux/UNIX" {
+		t.Errorf("Linux/UNIX prices expected but got %v", got)
+	}
+	if !aws.TimeValue(in.StartTime).Equal(since) {
+		t.Errorf("start time %s expected but got %s", since, aws.TimeValue(in.StartTime))
+	}
+}
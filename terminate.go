@@ -0,0 +1,267 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"path"
+	"strings"
+	"time"
+)
+
+/*
+ * Terminate cluster
+ */
+type AppTerminateOptions struct {
+	Names []string // cluster names, ids or glob patterns of names
+	Yes   bool     // terminate without confirmation
+	Force bool     // disable termination protection first
+	Wait  bool     // wait until the clusters are terminated
+}
+
+// TerminateWaitTimeout is how long WaitUntilClusterTerminated polls (60 times every 30 seconds).
+const TerminateWaitTimeout = 30 * time.Minute
+
+func (s *App) Terminate(o *AppTerminateOptions) error {
+	clusters, err := s.findTerminateTargets(o.Names)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(s.Stderr, "Terminate:")
+	var protected []*emr.Cluster
+	for _, c := range clusters {
+		s.printTerminateTarget(c)
+		if aws.BoolValue(c.TerminationProtected) {
+			protected = append(protected, c)
+		}
+	}
+
+	if len(protected) > 0 && !o.Force {
+		var names []string
+		for _, c := range protected {
+			names = append(names, fmt.Sprintf("%s (%s)", aws.StringValue(c.Name), aws.StringValue(c.Id)))
+		}
+		return fmt.Errorf("termination protection is enabled on %s: use --force to disable it and terminate", strings.Join(names, ", "))
+	}
+
+	if !o.Yes {
+		// never wait for an answer which cannot come, e.g. in cron or CI
+		if !s.Interactive {
+			return fmt.Errorf("stdin is not a terminal: use --yes to terminate without confirmation")
+		}
+		question := fmt.Sprintf("Terminate %s?", aws.StringValue(clusters[0].Name))
+		if len(clusters) > 1 {
+			question = fmt.Sprintf("Terminate %d clusters?", len(clusters))
+		}
+		ok, err := s.confirm(question)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("canceled: nothing is terminated")
+		}
+	}
+
+	if len(protected) > 0 {
+		in := emr.SetTerminationProtectionInput{TerminationProtected: aws.Bool(false)}
+		for _, c := range protected {
+			in.JobFlowIds = append(in.JobFlowIds, c.Id)
+		}
+		_, err := s.EMRAPI.SetTerminationProtection(&in)
+		if err != nil {
+			return apiError("SetTerminationProtection", err)
+		}
+		for _, c := range protected {
+			fmt.Fprintf(s.Stderr, "disabled termination protection of %s\n", aws.StringValue(c.Name))
+		}
+	}
+
+	in := emr.TerminateJobFlowsInput{}
+	for _, c := range clusters {
+		in.JobFlowIds = append(in.JobFlowIds, c.Id)
+	}
+	_, err = s.EMRAPI.TerminateJobFlows(&in)
+	if err != nil {
+		return apiError("TerminateJobFlows", err)
+	}
+	for _, c := range clusters {
+		fmt.Fprintf(s.Stderr, "terminating %s (%s)\n", aws.StringValue(c.Name), aws.StringValue(c.Id))
+	}
+
+	if o.Wait {
+		for _, c := range clusters {
+			err := s.waitClusterTerminated(aws.StringValue(c.Id))
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// waitClusterTerminated waits for the cluster to be terminated and prints
+// the state change reason, the normalized instance hours and where the logs are.
+func (s *App) waitClusterTerminated(id string) error {
+	in := emr.DescribeClusterInput{ClusterId: aws.String(id)}
+	werr := s.EMRAPI.WaitUntilClusterTerminated(&in)
+
+	// the waiter fails on TERMINATED_WITH_ERRORS, which is still the end of the cluster
+	out, err := s.EMRAPI.DescribeCluster(&in)
+	if err != nil {
+		return apiError("DescribeCluster", err)
+	}
+	c := out.Cluster
+	name := aws.StringValue(c.Name)
+
+	var state, reason string
+	if c.Status != nil {
+		state = aws.StringValue(c.Status.State)
+		if r := c.Status.StateChangeReason; r != nil {
+			reason = aws.StringValue(r.Code)
+			if m := aws.StringValue(r.Message); m != "" {
+				reason += ": " + m
+			}
+		}
+	}
+
+	if state != emr.ClusterStateTerminated && state != emr.ClusterStateTerminatedWithErrors {
+		if aerr, ok := werr.(awserr.Error); ok && aerr.Code() == request.WaiterResourceNotReadyErrorCode {
+			return &TimeoutError{Target: fmt.Sprintf("cluster %s (%s, %s)", name, id, state), Timeout: TerminateWaitTimeout}
+		}
+		return apiError("WaitUntilClusterTerminated", werr)
+	}
+
+	fmt.Fprintf(s.Stdout, "%s (%s) is %s\n", name, id, state)
+	if reason != "" {
+		fmt.Fprintf(s.Stdout, "  Reason: %s\n", reason)
+	}
+	fmt.Fprintf(s.Stdout, "  NormalizedInstanceHours: %d\n", aws.Int64Value(c.NormalizedInstanceHours))
+	if uri := aws.StringValue(c.LogUri); uri != "" {
+		fmt.Fprintf(s.Stdout, "  Logs: %s/%s/\n", strings.TrimSuffix(uri, "/"), id)
+	}
+	return nil
+}
+
+// findTerminateTargets resolves cluster ids, names and glob patterns of names to active clusters.
+// A pattern matching no cluster is an error, so that a mistyped name never terminates anything.
+func (s *App) findTerminateTargets(names []string) ([]*emr.Cluster, error) {
+	var ids []string
+	seen := map[string]bool{}
+	add := func(id string) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for _, name := range names {
+		if !strings.ContainsAny(name, "*?[") {
+			id, err := s.FindClusterId(name)
+			if err != nil {
+				return nil, err
+			}
+			add(id)
+			continue
+		}
+
+		if _, err := path.Match(name, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %s", name, err.Error())
+		}
+		in := emr.ListClustersInput{ClusterStates: aws.StringSlice(ClusterStateActive)}
+		var found []string
+		err := s.EMRAPI.ListClustersPages(&in, func(out *emr.ListClustersOutput, b bool) bool {
+			for _, c := range out.Clusters {
+				if ok, _ := path.Match(name, aws.StringValue(c.Name)); ok {
+					found = append(found, aws.StringValue(c.Id))
+				}
+			}
+			return true
+		})
+		if err != nil {
+			return nil, apiError("ListClusters", err)
+		}
+		if len(found) == 0 {
+			return nil, &NotFoundError{Kind: "cluster", Name: name}
+		}
+		for _, id := range found {
+			add(id)
+		}
+	}
+
+	var ret []*emr.Cluster
+	for _, id := range ids {
+		out, err := s.EMRAPI.DescribeCluster(&emr.DescribeClusterInput{ClusterId: aws.String(id)})
+		if err != nil {
+			return nil, apiError("DescribeCluster", err)
+		}
+		ret = append(ret, out.Cluster)
+	}
+	return ret, nil
+}
+
+// printTerminateTarget prints what is lost by terminating the cluster.
+// Running YARN applications are shown as unknown if the master cannot be reached.
+func (s *App) printTerminateTarget(c *emr.Cluster) {
+	fmt.Fprintf(s.Stderr, "  %s (%s)\n", aws.StringValue(c.Name), aws.StringValue(c.Id))
+
+	var state, age string
+	if c.Status != nil {
+		state = aws.StringValue(c.Status.State)
+		if c.Status.Timeline != nil && c.Status.Timeline.CreationDateTime != nil {
+			age = ", age " + formatAge(time.Since(aws.TimeValue(c.Status.Timeline.CreationDateTime)))
+		}
+	}
+	fmt.Fprintf(s.Stderr, "    %s%s, %d normalized instance hours\n", state, age, aws.Int64Value(c.NormalizedInstanceHours))
+
+	if master := aws.StringValue(c.MasterPublicDnsName); master != "" {
+		apps, err := s.getRunningYarnApps(master)
+		if err != nil {
+			fmt.Fprintf(s.Stderr, "    running YARN apps: unknown (%s)\n", err.Error())
+		} else if len(apps) > 0 {
+			fmt.Fprintf(s.Stderr, "    running YARN apps: %d (%s)\n", len(apps), strings.Join(apps, ", "))
+		} else {
+			fmt.Fprintln(s.Stderr, "    running YARN apps: none")
+		}
+	}
+
+	if aws.BoolValue(c.TerminationProtected) {
+		fmt.Fprintln(s.Stderr, "    termination protected")
+	}
+}
+
+// getRunningYarnApps returns the names of running YARN applications.
+func (s *App) getRunningYarnApps(master string) ([]string, error) {
+	url := fmt.Sprintf("http://%s:8088/ws/v1/cluster/apps?states=RUNNING", master)
+	buf, err := s.OpHandler.HttpGet(url)
+	if err != nil {
+		return nil, &UnreachableError{URL: url, Err: err}
+	}
+
+	dat := yarnAppsBuffer{}
+	err = json.Unmarshal(buf, &dat)
+	if err != nil {
+		return nil, err
+	}
+
+	var ret []string
+	for _, app := range dat.Apps.App {
+		ret = append(ret, app.Name)
+	}
+	return ret, nil
+}
+
+// formatAge prints the duration in days, hours and minutes, e.g. 2d3h15m.
+func formatAge(d time.Duration) string {
+	m := int64(d.Minutes())
+	if m < 60 {
+		return fmt.Sprintf("%dm", m)
+	}
+	if m < 24*60 {
+		return fmt.Sprintf("%dh%dm", m/60, m%60)
+	}
+	return fmt.Sprintf("%dd%dh%dm", m/(24*60), m/60%24, m%60)
+}
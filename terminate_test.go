@@ -0,0 +1,217 @@
+package main
+
+import (
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// mockTerminateClusters mocks active clusters test, etl-1 and etl-2. etl-2 is termination protected.
+func mockTerminateClusters(a *MockApp) {
+	var clusters []*emr.Cluster
+	for i, name := range []string{"test", "etl-1", "etl-2"} {
+		id := fmt.Sprintf("j-%08d", i)
+		clusters = append(clusters, &emr.Cluster{
+			Id:                      aws.String(id),
+			Name:                    aws.String(name),
+			MasterPublicDnsName:     aws.String("master-" + name),
+			NormalizedInstanceHours: aws.Int64(10),
+			TerminationProtected:    aws.Bool(name == "etl-2"),
+			Status: &emr.ClusterStatus{
+				State: aws.String(emr.ClusterStateWaiting),
+				Timeline: &emr.ClusterTimeline{
+					CreationDateTime: aws.Time(time.Now().Add(-3*time.Hour - 30*time.Second)),
+				},
+			},
+		})
+	}
+	mockClusters(a, clusters...)
+	a.OpHandler.MockGet = func(url string) ([]byte, error) {
+		return []byte(`{"apps": {"app": [{"name": "etl-daily"}, {"name": "adhoc-query"}]}}`), nil
+	}
+}
+
+func terminatedIds(a *MockApp) []string {
+	if a.EMRAPI.LastTerminateJobFlowsInput == nil {
+		return nil
+	}
+	return aws.StringValueSlice(a.EMRAPI.LastTerminateJobFlowsInput.JobFlowIds)
+}
+
+/*
+ * Test Terminate
+ */
+func TestTerminate(t *testing.T) {
+	a := NewMockApp()
+	mockTerminateClusters(a)
+	a.Stdin.WriteString("y\n")
+
+	err := a.Terminate(&AppTerminateOptions{Names: []string{"test"}})
+	if err != nil {
+		t.Fatalf("Termiante command expected to success but failed with %s", err.Error())
+	}
+
+	if exp, ids := []string{"j-00000000"}, terminatedIds(a); !reflect.DeepEqual(exp, ids) {
+		t.Errorf("%v expected but got %v", exp, ids)
+	}
+
+	msg := a.Stderr.String()
+	for _, exp := range []string{
+		"  test (j-00000000)\n",
+		"    WAITING, age 3h0m, 10 normalized instance hours\n",
+		"    running YARN apps: 2 (etl-daily, adhoc-query)\n",
+		"Terminate test? [y/N] ",
+		"terminating test (j-00000000)\n",
+	} {
+		if !strings.Contains(msg, exp) {
+			t.Errorf("'%s' expected in '%s'", exp, msg)
+		}
+	}
+}
+
+func TestTerminateCanceled(t *testing.T) {
+	a := NewMockApp()
+	mockTerminateClusters(a)
+
+	err := a.Terminate(&AppTerminateOptions{Names: []string{"test"}})
+	if err == nil || !strings.Contains(err.Error(), "canceled") {
+		t.Errorf("Terminate command expected to fail as canceled but got %v", err)
+	}
+
+	if a.EMRAPI.LastTerminateJobFlowsInput != nil {
+		t.Errorf("TerminateJobFlows API is expected not to be called without confirmation")
+	}
+}
+
+func TestTerminateNotInteractive(t *testing.T) {
+	a := NewMockApp()
+	mockTerminateClusters(a)
+	a.Interactive = false
+	a.Stdin.WriteString("y\n")
+
+	err := a.Terminate(&AppTerminateOptions{Names: []string{"test"}})
+	if err == nil || !strings.Contains(err.Error(), "use --yes") {
+		t.Errorf("Terminate command expected to fail without --yes but got %v", err)
+	}
+
+	if a.EMRAPI.LastTerminateJobFlowsInput != nil {
+		t.Errorf("TerminateJobFlows API is expected not to be called without --yes")
+	}
+	if msg := a.Stderr.String(); strings.Contains(msg, "[y/N]") {
+		t.Errorf("confirmation expected not to be asked but got '%s'", msg)
+	}
+}
+
+func TestTerminatePattern(t *testing.T) {
+	a := NewMockApp()
+	mockTerminateClusters(a)
+
+	err := a.Terminate(&AppTerminateOptions{Names: []string{"etl-*", "etl-2", "test"}, Yes: true, Force: true})
+	if err != nil {
+		t.Fatalf("Termiante command expected to success but failed with %s", err.Error())
+	}
+
+	if exp, ids := []string{"j-00000001", "j-00000002", "j-00000000"}, terminatedIds(a); !reflect.DeepEqual(exp, ids) {
+		t.Errorf("%v expected but got %v", exp, ids)
+	}
+}
+
+func TestTerminatePatternNotFound(t *testing.T) {
+	a := NewMockApp()
+	mockTerminateClusters(a)
+
+	err := a.Terminate(&AppTerminateOptions{Names: []string{"test", "etl-x*"}, Yes: true})
+	if _, ok := err.(*NotFoundError); !ok {
+		t.Fatalf("NotFoundError expected but got %v", err)
+	}
+	if a.EMRAPI.LastTerminateJobFlowsInput != nil {
+		t.Errorf("TerminateJobFlows API is expected not to be called")
+	}
+}
+
+func TestTerminateProtected(t *testing.T) {
+	a := NewMockApp()
+	mockTerminateClusters(a)
+
+	err := a.Terminate(&AppTerminateOptions{Names: []string{"etl-2"}, Yes: true})
+	if err == nil || !strings.Contains(err.Error(), "etl-2 (j-00000002): use --force") {
+		t.Fatalf("termination protection error expected but got %v", err)
+	}
+	if a.EMRAPI.LastTerminateJobFlowsInput != nil {
+		t.Errorf("TerminateJobFlows API is expected not to be called")
+	}
+
+	err = a.Terminate(&AppTerminateOptions{Names: []string{"etl-2"}, Yes: true, Force: true})
+	if err != nil {
+		t.Fatalf("Termiante command expected to success but failed with %s", err.Error())
+	}
+
+	input := a.EMRAPI.LastSetTerminationProtectionInput
+	if input == nil {
+		t.Fatalf("SetTerminationProtection API is expected to be called")
+	}
+	if aws.BoolValue(input.TerminationProtected) || aws.StringValue(input.JobFlowIds[0]) != "j-00000002" {
+		t.Errorf("termination protection of j-00000002 expected to be disabled but got %v", input)
+	}
+	if exp, ids := []string{"j-00000002"}, terminatedIds(a); !reflect.DeepEqual(exp, ids) {
+		t.Errorf("%v expected but got %v", exp, ids)
+	}
+}
+
+func TestTerminateWait(t *testing.T) {
+	a := NewMockApp()
+	mockTerminateClusters(a)
+	describe := a.EMRAPI.MockDescribeCluster
+	a.EMRAPI.MockWaitUntilClusterTerminated = func(input *emr.DescribeClusterInput) error {
+		out, _ := describe(input)
+		c := *out.Cluster
+		c.LogUri = aws.String("s3://logs/emr/")
+		c.NormalizedInstanceHours = aws.Int64(16)
+		c.Status = &emr.ClusterStatus{
+			State: aws.String(emr.ClusterStateTerminated),
+			StateChangeReason: &emr.ClusterStateChangeReason{
+				Code:    aws.String(emr.ClusterStateChangeReasonCodeUserRequest),
+				Message: aws.String("Terminated by user request"),
+			},
+		}
+		a.EMRAPI.MockDescribeCluster = func(*emr.DescribeClusterInput) (*emr.DescribeClusterOutput, error) {
+			return &emr.DescribeClusterOutput{Cluster: &c}, nil
+		}
+		return nil
+	}
+
+	err := a.Terminate(&AppTerminateOptions{Names: []string{"test"}, Yes: true, Wait: true})
+	if err != nil {
+		t.Fatalf("Termiante command expected to success but failed with %s", err.Error())
+	}
+
+	exp := "test (j-00000000) is TERMINATED\n" +
+		"  Reason: USER_REQUEST: Terminated by user request\n" +
+		"  NormalizedInstanceHours: 16\n" +
+		"  Logs: s3://logs/emr/j-00000000/\n"
+	if out := a.Stdout.String(); exp != out {
+		t.Errorf("'%s' expected but got '%s'", exp, out)
+	}
+}
+
+func TestTerminateWaitTimeout(t *testing.T) {
+	a := NewMockApp()
+	mockTerminateClusters(a)
+	a.EMRAPI.MockWaitUntilClusterTerminated = func(input *emr.DescribeClusterInput) error {
+		return awserr.New(request.WaiterResourceNotReadyErrorCode, "exceeded wait attempts", nil)
+	}
+
+	err := a.Terminate(&AppTerminateOptions{Names: []string{"test"}, Yes: true, Wait: true})
+	if _, ok := err.(*TimeoutError); !ok {
+		t.Fatalf("TimeoutError expected but got %v", err)
+	}
+	if exp := "timed out after 30m0s waiting for cluster test (j-00000000, WAITING)"; exp != err.Error() {
+		t.Errorf("'%s' expected but got '%s'", exp, err.Error())
+	}
+}
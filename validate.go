@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/emr"
+	"gopkg.in/yaml.v2"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	releaseLabelPattern = regexp.MustCompile(`^emr-\d+\.\d+\.\d+$`)
+	yamlErrorPattern    = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+	unknownFieldPattern = regexp.MustCompile(`^field (\S+) not found`)
+)
+
+/*
+ * Validation errors
+ */
+
+// ConfigError is a problem found in a rendered cluster config.
+// Line is 1-origin and 0 if the location is unknown.
+type ConfigError struct {
+	Line    int
+	Text    string
+	Message string
+}
+
+// ValidationError is returned when a cluster config is invalid.
+type ValidationError struct {
+	Filename string
+	Errors   []*ConfigError
+	Merged   bool // lines are of the config merged by extends, not of the file
+}
+
+func (e *ValidationError) Error() string {
+	buf := bytes.NewBufferString(fmt.Sprintf("%s is invalid:", e.Filename))
+	for _, ce := range e.Errors {
+		if ce.Line > 0 && e.Merged {
+			fmt.Fprintf(buf, "\n  line %d of the merged config: %s\n    | %s", ce.Line, ce.Message, ce.Text)
+		} else if ce.Line > 0 {
+			fmt.Fprintf(buf, "\n  line %d: %s\n    | %s", ce.Line, ce.Message, ce.Text)
+		} else {
+			fmt.Fprintf(buf, "\n  %s", ce.Message)
+		}
+	}
+	return buf.String()
+}
+
+/*
+ * Validate command
+ */
+type AppValidateOptions struct {
+	Name string
+	Vars map[string]string
+	TemplateOptions
+}
+
+func (s *App) Validate(o *AppValidateOptions) error {
+	filename, err := s.ConfigFile(o.Filename, o.Profile)
+	if err != nil {
+		return err
+	}
+
+	loader, err := s.newConfigLoader(o.Name, o.Vars, o.VarFiles)
+	if err != nil {
+		return err
+	}
+
+	_, err = loader.LoadConfig(filename)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(s.Stderr, "%s is valid\n", filename)
+	return nil
+}
+
+// decodeClusterTemplate decodes the rendered config rejecting unknown fields, and validates it.
+func decodeClusterTemplate(filename string, dat []byte) (*ClusterTemplate, error) {
+	ret := ClusterTemplate{}
+	err := yaml.UnmarshalStrict(dat, &ret)
+	if err != nil {
+		return nil, &ValidationError{Filename: filename, Errors: yamlConfigErrors(dat, err)}
+	}
+
+	errs := validateClusterConfig(&ret.RunJobFlowInput, dat)
+	errs = append(errs, validateScalingConfig(ret.Scaling, &ret.RunJobFlowInput, dat)...)
+	errs = append(errs, validateScheduleConfig(ret.Schedule, &ret, dat)...)
+	errs = append(errs, validateIdleTimeout(ret.IdleTimeout, &ret.RunJobFlowInput, dat)...)
+	if len(errs) > 0 {
+		return nil, &ValidationError{Filename: filename, Errors: errs}
+	}
+
+	return &ret, nil
+}
+
+func validateClusterConfig(config *emr.RunJobFlowInput, dat []byte) []*ConfigError {
+	var errs []*ConfigError
+	lines := strings.Split(string(dat), "\n")
+
+	// parameters required by the API
+	errs = append(errs, paramErrors("", config.Validate())...)
+
+	if l := aws.StringValue(config.ReleaseLabel); l != "" && !releaseLabelPattern.MatchString(l) {
+		errs = append(errs, newConfigError(lines, keyPattern("releaselabel"), 1,
+			fmt.Sprintf("unknown release label format %s (emr-X.Y.Z expected)", l)))
+	}
+
+	if config.Instances == nil || len(config.Instances.InstanceGroups) == 0 {
+		return errs
+	}
+
+	masters := 0
+	seen := map[string]int{}
+	for _, ig := range config.Instances.InstanceGroups {
+		name := aws.StringValue(ig.Name)
+		seen[name] += 1
+		pat := keyValuePattern("name", name)
+
+		if aws.StringValue(ig.InstanceRole) == emr.InstanceRoleTypeMaster {
+			masters += 1
+		}
+		if seen[name] == 2 {
+			errs = append(errs, newConfigError(lines, pat, 2,
+				fmt.Sprintf("instance group %s is defined more than once", name)))
+		}
+		if aws.StringValue(ig.Market) == emr.MarketTypeSpot && aws.StringValue(ig.BidPrice) == "" {
+			errs = append(errs, newConfigError(lines, pat, seen[name],
+				fmt.Sprintf("instance group %s: bidprice is required for SPOT market", name)))
+		}
+	}
+	if masters != 1 {
+		errs = append(errs, newConfigError(lines, keyPattern("instancegroups"), 1,
+			fmt.Sprintf("exactly one MASTER instance group expected but got %d", masters)))
+	}
+
+	return errs
+}
+
+// paramErrors converts the errors of the SDK parameter validation, prefixing them with the section if given.
+func paramErrors(prefix string, err error) []*ConfigError {
+	if err == nil {
+		return nil
+	}
+	if prefix != "" {
+		prefix += ": "
+	}
+	perr, ok := err.(request.ErrInvalidParams)
+	if !ok {
+		return []*ConfigError{{Message: prefix + err.Error()}}
+	}
+	var errs []*ConfigError
+	for _, e := range perr.OrigErrs() {
+		errs = append(errs, &ConfigError{Message: prefix + e.Error()})
+	}
+	return errs
+}
+
+// yamlConfigErrors converts the errors from the yaml decoder, which are prefixed by line numbers.
+func yamlConfigErrors(dat []byte, err error) []*ConfigError {
+	var msgs []string
+	if terr, ok := err.(*yaml.TypeError); ok {
+		msgs = terr.Errors
+	} else {
+		msgs = []string{err.Error()}
+	}
+
+	lines := strings.Split(string(dat), "\n")
+	var errs []*ConfigError
+	for _, msg := range msgs {
+		ce := &ConfigError{Message: msg}
+		if m := yamlErrorPattern.FindStringSubmatch(msg); m != nil {
+			ce.Line, _ = strconv.Atoi(m[1])
+			ce.Message = m[2]
+			// the decoder reports the line of the enclosing mapping for unknown fields
+			if f := unknownFieldPattern.FindStringSubmatch(ce.Message); f != nil {
+				if l := findLineFrom(lines, keyPattern(f[1]), ce.Line); l > 0 {
+					ce.Line = l
+				}
+			}
+			if ce.Line <= len(lines) {
+				ce.Text = lines[ce.Line-1]
+			}
+		}
+		errs = append(errs, ce)
+	}
+	return errs
+}
+
+// newConfigError returns ConfigError located at the nth line matching pat.
+func newConfigError(lines []string, pat *regexp.Regexp, nth int, msg string) *ConfigError {
+	ce := &ConfigError{Message: msg}
+	n := 0
+	for i, l := range lines {
+		if pat.MatchString(l) {
+			if n += 1; n == nth {
+				ce.Line = i + 1
+				ce.Text = l
+				break
+			}
+		}
+	}
+	return ce
+}
+
+// findLineFrom returns the first line matching pat at or after the line.
+func findLineFrom(lines []string, pat *regexp.Regexp, line int) int {
+	for i := line - 1; i >= 0 && i < len(lines); i++ {
+		if pat.MatchString(lines[i]) {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+func keyPattern(key string) *regexp.Regexp {
+	return regexp.MustCompile(`^[\s-]*` + regexp.QuoteMeta(key) + `\s*:`)
+}
+
+func keyValuePattern(key string, value string) *regexp.Regexp {
+	return regexp.MustCompile(`^[\s-]*` + regexp.QuoteMeta(key) + `\s*:\s*['"]?` + regexp.QuoteMeta(value) + `['"]?\s*$`)
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+/*
+ * Test Validate
+ */
+func TestValidate(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Validate(&AppValidateOptions{
+		Name:            "test",
+		TemplateOptions: TemplateOptions{Filename: "./cluster-sample.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Validate command expected to success but failed with %s", err.Error())
+	}
+}
+
+func TestValidateUnknownField(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Validate(&AppValidateOptions{
+		Name:            "test",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/validate/unknown-field.yml"},
+	})
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("ValidationError expected but got %v", err)
+	}
+
+	if n := len(verr.Errors); 1 != n {
+		t.Fatalf("1 error expected but got %d: %s", n, verr)
+	}
+	if l := verr.Errors[0].Line; 10 != l {
+		t.Errorf("error at line 10 expected but got %d: %s", l, verr)
+	}
+}
+
+func TestValidateExtendedUnknownField(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Validate(&AppValidateOptions{
+		Name:            "test",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/validate/extends.yml"},
+	})
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("ValidationError expected but got %v", err)
+	}
+
+	if !verr.Merged {
+		t.Errorf("lines of the merged config expected: %s", verr)
+	}
+	if msg := verr.Error(); !strings.Contains(msg, "of the merged config: field instancecout not found") {
+		t.Errorf("error in the merged config expected but got '%s'", msg)
+	}
+}
+
+func TestValidateSemantic(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Validate(&AppValidateOptions{
+		Name:            "test",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/validate/semantic.yml"},
+	})
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		t.Fatalf("ValidationError expected but got %v", err)
+	}
+
+	exp := []struct {
+		line int
+		msg  string
+	}{
+		{3, "unknown release label format emr5.9 (emr-X.Y.Z expected)"},
+		{11, "instance group task: bidprice is required for SPOT market"},
+		{16, "instance group core is defined more than once"},
+		{6, "exactly one MASTER instance group expected but got 0"},
+	}
+	if len(exp) != len(verr.Errors) {
+		t.Fatalf("%d errors expected but got %s", len(exp), verr)
+	}
+	for i, e := range exp {
+		got := verr.Errors[i]
+		if e.line != got.Line || e.msg != got.Message {
+			t.Errorf("line %d: %s expected but got line %d: %s", e.line, e.msg, got.Line, got.Message)
+		}
+	}
+}
+
+func TestStartInvalidConfig(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/validate/unknown-field.yml"},
+	})
+	if code := exitCode(err); ExitCodeInvalid != code {
+		t.Errorf("exit code %d expected but got %d (%v)", ExitCodeInvalid, code, err)
+	}
+	if a.EMRAPI.LastRunJobFlowInput != nil {
+		t.Errorf("RunJobFlow API is expected not to be called but called")
+	}
+}
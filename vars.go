@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"gopkg.in/yaml.v2"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var (
+	varsBlockPattern = regexp.MustCompile(`^vars:\s*(#.*)?$`)
+	varsLinePattern  = regexp.MustCompile(`^(\s|#|$)`)
+	extendsPattern   = regexp.MustCompile(`^extends:`)
+)
+
+/*
+ * Variable files and defaults
+ *
+ * Variables are resolved in the following order:
+ *   1. KEY=VAL arguments
+ *   2. --var-file files (later files win)
+ *   3. EMR_VAR_KEY environment variables
+ *   4. `vars` block in the template (the extending template wins)
+ *   5. the default given to lookup
+ */
+
+// LoadVarFiles reads YAML files of variables. Later files override earlier ones.
+func (l *configLoader) LoadVarFiles(files []string) error {
+	for _, f := range files {
+		dat, err := ioutil.ReadFile(f)
+		if err != nil {
+			return err
+		}
+
+		m := map[string]interface{}{}
+		err = yaml.Unmarshal(dat, &m)
+		if err != nil {
+			return fmt.Errorf("%s: %s", f, err.Error())
+		}
+
+		for k, v := range m {
+			l.fileVars[k] = &VariableLookup{Key: k, Value: v, Source: VariableSourceVarFile + " " + f}
+		}
+	}
+	return nil
+}
+
+// addDefaults registers the `vars` block of a template unless an extending template defines them.
+func (l *configLoader) addDefaults(filename string, vars map[string]interface{}) {
+	for k, v := range vars {
+		if _, ok := l.defaults[k]; !ok {
+			l.defaults[k] = &VariableLookup{Key: k, Value: v, Source: VariableSourceTemplate + " " + filename}
+		}
+	}
+}
+
+// loadDefaults registers the `vars` blocks of the template and the templates it extends before rendering,
+// so that the extending template sees the defaults of its base. Cycles are left to be reported by load.
+func (l *configLoader) loadDefaults(filename string, seen map[string]bool) error {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return err
+	}
+	if seen[abs] {
+		return nil
+	}
+	seen[abs] = true
+
+	dat, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+	defaults, text, err := extractVarsBlock(filename, string(dat))
+	if err != nil {
+		return err
+	}
+	l.addDefaults(filename, defaults)
+
+	if base := findExtends(text); base != "" {
+		return l.loadDefaults(relativePath(filename, base), seen)
+	}
+	return nil
+}
+
+// findExtends returns the file given by the top-level `extends` line of a template before rendering,
+// or "" if there is none or it is rendered from variables.
+func findExtends(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if !extendsPattern.MatchString(line) {
+			continue
+		}
+		if strings.Contains(line, "{{") {
+			return ""
+		}
+		m := struct {
+			Extends string `yaml:"extends"`
+		}{}
+		if err := yaml.Unmarshal([]byte(line), &m); err != nil {
+			return ""
+		}
+		return m.Extends
+	}
+	return ""
+}
+
+// Variables returns all the variables known to the loader with their values and sources.
+func (l *configLoader) Variables() []*VariableLookup {
+	keys := map[string]bool{}
+	for k := range l.vars {
+		keys[k] = true
+	}
+	for k := range l.fileVars {
+		keys[k] = true
+	}
+	for k := range l.defaults {
+		keys[k] = true
+	}
+	for _, e := range os.Environ() {
+		if kv := strings.SplitN(e, "=", 2); strings.HasPrefix(kv[0], "EMR_VAR_") {
+			keys[strings.ToLower(strings.TrimPrefix(kv[0], "EMR_VAR_"))] = true
+		}
+	}
+
+	var ret []*VariableLookup
+	for k := range keys {
+		if v := l.find(k); v != nil {
+			ret = append(ret, v)
+		}
+	}
+	sort.Slice(ret, func(i, j int) bool { return ret[i].Key < ret[j].Key })
+	return ret
+}
+
+// data returns the resolved variables passed to templates as `.`.
+func (l *configLoader) data() map[string]interface{} {
+	ret := map[string]interface{}{}
+	for _, v := range l.Variables() {
+		ret[v.Key] = v.Value
+	}
+	return ret
+}
+
+// extractVarsBlock splits the top-level `vars` block from a template.
+// The block is replaced with empty lines to keep line numbers of the rendered template.
+func extractVarsBlock(filename string, text string) (map[string]interface{}, string, error) {
+	lines := strings.Split(text, "\n")
+
+	start := -1
+	for i, l := range lines {
+		if varsBlockPattern.MatchString(l) {
+			start = i
+			break
+		}
+	}
+	if start < 0 {
+		return nil, text, nil
+	}
+
+	end := start + 1
+	for end < len(lines) && varsLinePattern.MatchString(lines[end]) {
+		end++
+	}
+
+	block := strings.Join(lines[start:end], "\n")
+	m := struct {
+		Vars map[string]interface{} `yaml:"vars"`
+	}{}
+	err := yaml.Unmarshal([]byte(block), &m)
+	if err != nil {
+		return nil, "", fmt.Errorf("%s: vars: %s", filename, err.Error())
+	}
+
+	for i := start; i < end; i++ {
+		lines[i] = ""
+	}
+	return m.Vars, strings.Join(lines, "\n"), nil
+}
+
+/*
+ * VARS command
+ */
+type AppVarsOptions struct {
+	Name string
+	Vars map[string]string
+	TemplateOptions
+}
+
+func (s *App) Vars(o *AppVarsOptions) error {
+	filename, err := s.ConfigFile(o.Filename, o.Profile)
+	if err != nil {
+		return err
+	}
+
+	loader, err := s.newConfigLoader(o.Name, o.Vars, o.VarFiles)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = loader.Load(filename)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range loader.Variables() {
+		fmt.Fprintf(s.Stdout, "%s = %s (%s)\n", v.Key, loader.Mask(fmt.Sprintf("%v", v.Value)), v.Source)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"github.com/aws/aws-sdk-go/aws"
+	"os"
+	"testing"
+)
+
+/*
+ * Test Vars
+ */
+func TestStartWithVarFiles(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Start(&AppStartOptions{
+		Name: "test",
+		TemplateOptions: TemplateOptions{
+			Filename: "./testdata/vars/cluster.yml",
+			VarFiles: []string{"./testdata/vars/prod.yml", "./testdata/vars/prod-large.yml"},
+		},
+		Vars: map[string]string{"instancetype": "r4.xlarge"},
+	})
+	if err != nil {
+		t.Fatalf("Start command expected to success but failed with %s", err.Error())
+	}
+
+	input := a.EMRAPI.LastRunJobFlowInput
+	if id := aws.StringValue(input.Instances.Ec2SubnetId); "subnet-11111111" != id {
+		t.Errorf("subnet-11111111 expected but got %s", id)
+	}
+	ig := input.Instances.InstanceGroups[1]
+	if n := aws.Int64Value(ig.InstanceCount); 20 != n {
+		t.Errorf("20 expected but got %d", n)
+	}
+	if typ := aws.StringValue(ig.InstanceType); "r4.xlarge" != typ {
+		t.Errorf("r4.xlarge expected but got %s", typ)
+	}
+}
+
+func TestVars(t *testing.T) {
+	os.Setenv("EMR_VAR_SUBNET", "subnet-22222222")
+	defer os.Unsetenv("EMR_VAR_SUBNET")
+
+	a := NewMockApp()
+
+	err := a.Vars(&AppVarsOptions{
+		Name: "test",
+		TemplateOptions: TemplateOptions{
+			Filename: "./testdata/vars/cluster.yml",
+			VarFiles: []string{"./testdata/vars/prod-large.yml"},
+		},
+		Vars: map[string]string{"name": "test"},
+	})
+	if err != nil {
+		t.Fatalf("Vars command expected to success but failed with %s", err.Error())
+	}
+
+	exp := `core = 20 (var-file ./testdata/vars/prod-large.yml)
+instancetype = m3.xlarge (vars in ./testdata/vars/cluster.yml)
+name = test (argument)
+subnet = subnet-22222222 (env EMR_VAR_SUBNET)
+`
+	if out := a.Stdout.String(); exp != out {
+		t.Errorf("'%s' expected but got '%s'", exp, out)
+	}
+}
+
+func TestVarsBlockKeepsLineNumbers(t *testing.T) {
+	text := "---\nvars:\n  a: 1\n\nname: x\n"
+	vars, out, err := extractVarsBlock("test.yml", text)
+	if err != nil {
+		t.Fatalf("extractVarsBlock expected to success but failed with %s", err.Error())
+	}
+	if v := vars["a"]; 1 != v {
+		t.Errorf("1 expected but got %v", v)
+	}
+	if exp := "---\n\n\n\nname: x\n"; exp != out {
+		t.Errorf("%q expected but got %q", exp, out)
+	}
+}
+
+func TestStartWithExtendedVars(t *testing.T) {
+	a := NewMockApp()
+
+	err := a.Start(&AppStartOptions{
+		Name:            "test",
+		TemplateOptions: TemplateOptions{Filename: "./testdata/vars/spark.yml"},
+	})
+	if err != nil {
+		t.Fatalf("Start command expected to success but failed with %s", err.Error())
+	}
+
+	// instancetype comes from the base and core from the extending template
+	for _, ig := range a.EMRAPI.LastRunJobFlowInput.Instances.InstanceGroups[1:] {
+		if typ := aws.StringValue(ig.InstanceType); "m3.xlarge" != typ {
+			t.Errorf("%s: m3.xlarge expected but got %s", aws.StringValue(ig.Name), typ)
+		}
+		if n := aws.Int64Value(ig.InstanceCount); 4 != n {
+			t.Errorf("%s: 4 expected but got %d", aws.StringValue(ig.Name), n)
+		}
+	}
+}